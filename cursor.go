@@ -0,0 +1,278 @@
+package dbw
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strconv"
+)
+
+// DefaultCursorBatchSize holds default amount of rows fetched from cursor at once.
+const DefaultCursorBatchSize = 1000
+
+// Cursor reads the result of a query by batches using server-side cursor
+// (DECLARE ... CURSOR / FETCH n). It allows to process huge results
+// without holding them in memory. Cursor works inside a transaction.
+//
+// Cursor provides the same interface as Iterator.
+//
+//	c := db.DeclareCursor(ctx, tx, 5000, "SELECT id, name FROM customers")
+//	defer c.Close()
+//	for c.Next() {
+//		if err := c.ScanRow(&customer); err != nil {
+//			return err
+//		}
+//	}
+//	return c.Err()
+type Cursor struct {
+	db    *DB
+	tx    *Tx
+	ownTx bool
+	ctx   context.Context
+	name  string
+	batch int
+
+	typ   reflect.Type
+	addrs func(row interface{}) []interface{}
+
+	// it holds iterator over the last fetched batch.
+	it      *Iterator
+	inBatch int
+
+	// RowsFetched holds number of rows fetched from the cursor.
+	RowsFetched int
+
+	// Batches holds number of FETCH statements executed.
+	Batches int
+
+	err    error
+	closed bool
+}
+
+// DeclareCursor declares cursor for query qry in transaction tx. If tx is nil,
+// a read only transaction is started and finished by Close. Parameter
+// batch defines amount of rows fetched at once, DefaultCursorBatchSize is
+// used if batch is not positive.
+func (db *DB) DeclareCursor(ctx context.Context, tx *Tx, batch int, qry string, args ...interface{}) *Cursor {
+
+	if batch <= 0 {
+		batch = DefaultCursorBatchSize
+	}
+
+	c := &Cursor{
+		db:    db,
+		tx:    tx,
+		ctx:   ctx,
+		batch: batch,
+		name:  "dbw_cursor_" + strconv.FormatUint(db.nextStmtNum(), 10),
+	}
+
+	if c.tx == nil {
+		c.tx = db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		c.ownTx = true
+		if err := c.tx.Err(); err != nil {
+			c.err = parseError(err)
+			c.closed = true
+			return c
+		}
+	}
+
+	qry, args = db.expandSlices(ctx, qry, args)
+	if _, err := c.instance("DECLARE "+c.name+" NO SCROLL CURSOR FOR "+qry).ExecContext(ctx, args...); err != nil {
+		c.err = WrapError(qry, err, db.redact(qry, args)...)
+		c.finish()
+	}
+
+	return c
+}
+
+// instance returns instance of cursor statement qry executed in the cursor
+// transaction. Cursor statements are unique, so they are sent unprepared
+// and not cached.
+func (c *Cursor) instance(qry string) *StmtInstance {
+	s := &Stmt{db: c.db, text: qry}
+	return newStmtInstanceTx(c.tx, s, c.db.nextStmtNum(), nil).Unprepared()
+}
+
+// DeclareCursorOf declares cursor like DeclareCursor. Method Row() returns
+// rows as new values of model type (pointer to struct).
+func (db *DB) DeclareCursorOf(ctx context.Context, tx *Tx, batch int, model interface{}, qry string, args ...interface{}) *Cursor {
+	c := db.DeclareCursor(ctx, tx, batch, qry, args...)
+	c.typ, _ = structType(model)
+	return c
+}
+
+// Next prepares the next row for reading, fetching the next batch
+// from the cursor if needed. Closes the cursor if rows are exhausted
+// or an error occurred.
+func (c *Cursor) Next() bool {
+
+	for !c.closed {
+		if c.it != nil {
+			if c.it.Next() {
+				c.inBatch++
+				c.RowsFetched++
+				return true
+			}
+
+			if err := c.it.Err(); err != nil {
+				c.fail(err)
+				return false
+			}
+
+			if c.inBatch < c.batch {
+				c.Close()
+				return false
+			}
+		}
+
+		c.fetch()
+	}
+	return false
+}
+
+// fetch executes FETCH statement for the next batch.
+func (c *Cursor) fetch() {
+	si := c.instance("FETCH " + strconv.Itoa(c.batch) + " FROM " + c.name).QueryContext(c.ctx)
+	if err := si.Err(); err != nil {
+		c.fail(err)
+		return
+	}
+
+	c.it = si.Iter()
+	c.it.typ = c.typ
+	c.it.addrs = c.addrs
+	c.inBatch = 0
+	c.Batches++
+}
+
+// Scan reads columns of the current row into dest by position.
+func (c *Cursor) Scan(dest ...interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.check(c.it.Scan(dest...))
+}
+
+// ScanRow reads the current row into struct referenced by row. See Iterator.ScanRow.
+func (c *Cursor) ScanRow(row interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.check(c.it.ScanRow(row))
+}
+
+// Row returns the current row as a new value of the model type.
+func (c *Cursor) Row() interface{} {
+	if c.err != nil {
+		return nil
+	}
+	row := c.it.Row()
+	c.check(c.it.Err())
+	return row
+}
+
+// Fetch reads all rows of the cursor into dest calling f after every row.
+func (c *Cursor) Fetch(f func() error, dest ...interface{}) error {
+	defer c.Close()
+
+	for c.Next() {
+		if err := c.Scan(dest...); err != nil {
+			return err
+		}
+
+		if f != nil {
+			if err := f(); err != nil {
+				return c.fail(err)
+			}
+		}
+	}
+	return c.Err()
+}
+
+// Err returns error occurred during reading the cursor.
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// Close closes the cursor and finishes transaction started by DeclareCursor.
+// It's safe to call Close several times.
+func (c *Cursor) Close() error {
+	if c.closed {
+		return c.err
+	}
+
+	if c.it != nil {
+		c.it.Close()
+	}
+
+	if _, err := c.instance("CLOSE " + c.name).ExecContext(c.ctx); err != nil && c.err == nil {
+		c.err = err
+	}
+
+	c.finish()
+	return c.err
+}
+
+// finish ends own transaction of the cursor.
+func (c *Cursor) finish() {
+	c.closed = true
+	if !c.ownTx {
+		return
+	}
+
+	if c.err != nil {
+		c.tx.Rollback()
+		return
+	}
+
+	if err := c.tx.Commit().Err(); err != nil {
+		c.err = parseError(err)
+	}
+}
+
+func (c *Cursor) check(err error) error {
+	if err != nil {
+		return c.fail(err)
+	}
+	return nil
+}
+
+func (c *Cursor) fail(err error) error {
+	if c.err == nil {
+		c.err = err
+	}
+	c.Close()
+	return c.err
+}
+
+// DoSelectCursor reads table rows complaints with where using server-side
+// cursor. Rows are fetched by batches of size batch. Function f is called
+// after every row scanned into row. If tx is nil, the cursor runs in its
+// own read only transaction.
+func (t *Table) DoSelectCursor(ctx context.Context, tx *Tx, batch int, where, order string, f func() error, row interface{}, params ...interface{}) error {
+
+	qry, params, err := t.selectQuery(ctx, tx, where, order, 0, 0, params...)
+	if err != nil {
+		return WrapError(t, err)
+	}
+
+	c := t.db.DeclareCursor(ctx, tx, batch, qry, params...)
+	return WrapError(t, c.Fetch(f, t.fieldAddrsSelect(row, "", All)...))
+}
+
+// DoSelectCursorIter returns cursor over table rows complaints with where.
+// Method Row() of the cursor returns rows as new values of table model.
+func (t *Table) DoSelectCursorIter(ctx context.Context, tx *Tx, batch int, where, order string, params ...interface{}) *Cursor {
+
+	qry, params, err := t.selectQuery(ctx, tx, where, order, 0, 0, params...)
+	if err != nil {
+		return &Cursor{err: WrapError(t, err), closed: true}
+	}
+
+	c := t.db.DeclareCursorOf(ctx, tx, batch, t.model, qry, params...)
+	c.addrs = func(row interface{}) []interface{} {
+		return t.fieldAddrsSelect(row, "", All)
+	}
+	return c
+}
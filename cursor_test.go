@@ -0,0 +1,249 @@
+package dbw
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/axkit/errors"
+)
+
+// cursorServer returns handler answering FETCH statements by rows of vals.
+// FETCH number failAt, if positive, fails with err.
+func cursorServer(vals [][]driver.Value, failAt int, err error) func(c *fakeCall) (*fakeRows, error) {
+	pos, fetches := 0, 0
+	return func(c *fakeCall) (*fakeRows, error) {
+		if !strings.HasPrefix(c.Query, "FETCH ") {
+			return nil, nil
+		}
+
+		if fetches++; fetches == failAt {
+			return nil, err
+		}
+
+		var n int
+		for _, f := range strings.Fields(c.Query)[1:2] {
+			for _, d := range f {
+				n = n*10 + int(d-'0')
+			}
+		}
+
+		end := pos + n
+		if end > len(vals) {
+			end = len(vals)
+		}
+		rows := &fakeRows{cols: []string{"id", "name"}, vals: vals[pos:end]}
+		pos = end
+		return rows, nil
+	}
+}
+
+func cursorRows(n int) [][]driver.Value {
+	res := make([][]driver.Value, n)
+	for i := range res {
+		res[i] = []driver.Value{int64(i + 1), "name"}
+	}
+	return res
+}
+
+func TestCursor_Batches(t *testing.T) {
+
+	tc := []struct {
+		name    string
+		rows    int
+		batch   int
+		batches int
+	}{
+		{"empty", 0, 2, 1},
+		{"partial", 3, 2, 2},
+		{"boundary", 4, 2, 3},
+		{"single", 1, 0, 1},
+	}
+
+	for i := range tc {
+		t.Run(tc[i].name, func(t *testing.T) {
+			db, srv := newFakeDB(t, cursorServer(cursorRows(tc[i].rows), 0, nil))
+			l := &recStmtLogger{}
+			db.SetStmtLogger(l)
+
+			c := db.DeclareCursor(context.Background(), nil, tc[i].batch, "SELECT id, name FROM items WHERE id > $1", 0)
+
+			var (
+				id   int
+				name string
+				ids  []int
+			)
+			for c.Next() {
+				if err := c.Scan(&id, &name); err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, id)
+			}
+			if err := c.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(ids) != tc[i].rows || c.RowsFetched != tc[i].rows {
+				t.Errorf("expected %d rows, got %v (%d)", tc[i].rows, ids, c.RowsFetched)
+			}
+			for j := range ids {
+				if ids[j] != j+1 {
+					t.Errorf("unexpected rows order %v", ids)
+					break
+				}
+			}
+			if c.Batches != tc[i].batches || srv.Count("FETCH ") != tc[i].batches {
+				t.Errorf("expected %d batches, got %d", tc[i].batches, c.Batches)
+			}
+			if srv.Count("CLOSE ") != 1 || srv.Count("COMMIT") != 1 {
+				t.Errorf("expected cursor closed and transaction committed: %v", srv.Log())
+			}
+
+			// DECLARE, FETCH per batch and CLOSE are logged.
+			if exp := tc[i].batches + 2; l.before != exp || len(l.after) != exp {
+				t.Errorf("expected %d logged statements, got %d/%d", exp, l.before, len(l.after))
+			}
+			if !srv.allClosed() {
+				t.Error("expected all rows closed")
+			}
+		})
+	}
+}
+
+func TestCursor_CloseAfterError(t *testing.T) {
+
+	failed := errors.New("fetch failed")
+	db, srv := newFakeDB(t, cursorServer(cursorRows(5), 2, failed))
+
+	c := db.DeclareCursor(context.Background(), nil, 2, "SELECT id, name FROM items")
+
+	n := 0
+	for c.Next() {
+		n++
+	}
+
+	if n != 2 {
+		t.Errorf("expected first batch read, got %d rows", n)
+	}
+	if !errors.Is(c.Err(), failed) {
+		t.Fatalf("expected fetch error, got %v", c.Err())
+	}
+	if err := c.Close(); !errors.Is(err, failed) {
+		t.Errorf("expected Close to return fetch error, got %v", err)
+	}
+	if c.Next() {
+		t.Error("expected closed cursor")
+	}
+
+	if srv.Count("CLOSE ") != 1 || srv.Count("ROLLBACK") != 1 || srv.Count("COMMIT") != 0 {
+		t.Errorf("expected single CLOSE and rollback: %v", srv.Log())
+	}
+}
+
+func TestCursor_DeclareFailed(t *testing.T) {
+
+	failed := errors.New("declare failed")
+	db, srv := newFakeDB(t, func(c *fakeCall) (*fakeRows, error) {
+		if strings.HasPrefix(c.Query, "DECLARE ") {
+			return nil, failed
+		}
+		return nil, nil
+	})
+
+	c := db.DeclareCursor(context.Background(), nil, 2, "SELECT id FROM items")
+	if c.Next() {
+		t.Error("expected no rows")
+	}
+	if !errors.Is(c.Err(), failed) {
+		t.Errorf("expected declare error, got %v", c.Err())
+	}
+	if srv.Count("FETCH ") != 0 || srv.Count("CLOSE ") != 0 || srv.Count("ROLLBACK") != 1 {
+		t.Errorf("unexpected statements: %v", srv.Log())
+	}
+}
+
+func TestTable_DoSelectCursor(t *testing.T) {
+
+	type Item struct {
+		ID   int
+		Name string
+	}
+
+	db, srv := newFakeDB(t, cursorServer(cursorRows(3), 0, nil))
+	tbl := NewTable(db, "items", &Item{})
+
+	tx := db.Begin()
+
+	var (
+		row Item
+		ids []int
+	)
+	err := tbl.DoSelectCursor(context.Background(), tx, 2, "id > $1", "id", func() error {
+		ids = append(ids, row.ID)
+		return nil
+	}, &row, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[2] != 3 {
+		t.Errorf("expected 3 rows, got %v", ids)
+	}
+
+	// cursor in a transaction of the caller leaves it open.
+	if srv.Count("COMMIT") != 0 {
+		t.Error("expected transaction of the caller not committed")
+	}
+	if err := tx.Commit().Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTable_DoSelectCursorFailed(t *testing.T) {
+
+	type Item struct {
+		ID   int
+		Name string
+	}
+
+	db, srv := newFakeDB(t, cursorServer(cursorRows(3), 0, nil))
+	tbl := NewTable(db, "items", &Item{})
+
+	var row Item
+	stop := errors.New("stop")
+	err := tbl.DoSelectCursor(context.Background(), nil, 2, "", "", func() error {
+		return stop
+	}, &row)
+	if !errors.Is(err, stop) {
+		t.Errorf("expected callback error, got %v", err)
+	}
+	if srv.Count("ROLLBACK") != 1 {
+		t.Errorf("expected own transaction rolled back: %v", srv.Log())
+	}
+}
+
+func TestTable_DoSelectCursorIter(t *testing.T) {
+
+	type Item struct {
+		ID   int
+		Name string
+	}
+
+	db, _ := newFakeDB(t, cursorServer(cursorRows(3), 0, nil))
+	tbl := NewTable(db, "items", &Item{})
+
+	c := tbl.DoSelectCursorIter(context.Background(), nil, 2, "", "")
+	defer c.Close()
+
+	n := 0
+	for c.Next() {
+		item, ok := c.Row().(*Item)
+		if !ok || item.ID != n+1 {
+			t.Fatalf("unexpected row %#v", c.Row())
+		}
+		n++
+	}
+	if err := c.Err(); err != nil || n != 3 {
+		t.Errorf("expected 3 rows, got %d, %v", n, err)
+	}
+}
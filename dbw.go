@@ -2,10 +2,13 @@ package dbw
 
 import (
 	"bytes"
+	"container/list"
 	"context"
 	"crypto/md5"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -86,6 +89,55 @@ type DB struct {
 	stmtSeq uint64
 
 	paramPlaceHolder ParamPlaceHolderType
+
+	// optMux protects settings of the database replaced by setters.
+	optMux sync.RWMutex
+
+	// stmtLogger is called around every statement execution, if not nil.
+	stmtLogger StmtLogger
+
+	// slowLog holds slow query log settings, if activated.
+	slowLog *slowQueryLog
+
+	txStats  txStats
+	errStats errStats
+
+	tracer Tracer
+
+	// commentTags holds SQL comment tags sorted by key. The slice is
+	// replaced on change, never modified in place.
+	commentTags []commentTag
+
+	// staticComment holds SQL comment built by static tags of commentTags.
+	staticComment string
+
+	// redactor masks sensitive parameters in logs and errors.
+	redactor *Redactor
+
+	// taggedColumns holds names of columns mapped to model fields tagged
+	// as secret or pii by tables of the database.
+	taggedColumns sync.Map
+
+	// stmtCacheSize limits size of ps, if positive. Accessed atomically.
+	stmtCacheSize int64
+
+	// lru holds not pinned cached statements, most recently used first.
+	lru    *list.List
+	lruMux sync.Mutex
+
+	stmtHits      uint64
+	stmtMisses    uint64
+	stmtEvictions uint64
+
+	// execMode holds default ExecMode. Accessed atomically.
+	execMode int32
+
+	// reprepareInTx is 1 if statements in transactions run under savepoint.
+	// Accessed atomically.
+	reprepareInTx int32
+
+	// sliceMode holds default SliceMode. Accessed atomically.
+	sliceMode int32
 }
 
 // Open tries once to establish connection to database.
@@ -124,6 +176,21 @@ func (db *DB) SetLogger(l *zerolog.Logger) {
 	db.logger = l.With().Str("layer", "db").Logger()
 }
 
+// SetStmtLogger sets logger called around every statement execution.
+// Logger of a statement set by Stmt.SetLogger takes precedence.
+func (db *DB) SetStmtLogger(l StmtLogger) {
+	db.optMux.Lock()
+	db.stmtLogger = l
+	db.optMux.Unlock()
+}
+
+// StmtLogger returns logger set by SetStmtLogger.
+func (db *DB) StmtLogger() StmtLogger {
+	db.optMux.RLock()
+	defer db.optMux.RUnlock()
+	return db.stmtLogger
+}
+
 // RepetableOpen tries to establish connection to database till ctx.Done() or
 // success. It calls func aff() after every failed attempt.
 func RepetableOpen(ctx context.Context, driverName, dataSourceName string, l *zerolog.Logger, aff func(string, int, error) time.Duration) (*DB, error) {
@@ -179,24 +246,24 @@ func calcHash(buf []byte) string {
 
 // Prepare prepares SQL statement. Unique statement name is hash generated.
 func (db *DB) Prepare(qry string) *Stmt {
-	return db.prepareContext(context.Background(), calcHash([]byte(qry)), qry)
+	return db.prepareContext(context.Background(), calcHash([]byte(qry)), qry, "", false)
 }
 
 // PrepareN prepares SQL statement. Parameter uid holds user defined unique statement name.
 func (db *DB) PrepareN(qry, uid string) *Stmt {
-	return db.prepareContext(context.Background(), uid, qry)
+	return db.prepareContext(context.Background(), uid, qry, "", true)
 }
 
 // PrepareContext prepares SQL statement. Unique statement name is hash generated.
 func (db *DB) PrepareContext(ctx context.Context, qry string) *Stmt {
-	return db.prepareContext(ctx, calcHash([]byte(qry)), qry)
+	return db.prepareContext(ctx, calcHash([]byte(qry)), qry, "", false)
 }
 
 // PrepareNamed prepared statement referenced by unique name. Later,
 // prepared statement can be taken from cache by uid.
 // It's expected that SQL parameters are always used for performance reasons.
 func (db *DB) PrepareContextN(ctx context.Context, qry, uid string) *Stmt {
-	return db.prepareContext(ctx, uid, qry)
+	return db.prepareContext(ctx, uid, qry, "", true)
 }
 
 var ErrUnknownPreparedStatement = errors.New("unknown prepared statement")
@@ -206,40 +273,55 @@ func (db *DB) Stmt(uid string) (*Stmt, bool) {
 	s, ok := db.ps[uid]
 	if ok {
 		db.mux.RUnlock()
+		atomic.AddUint64(&db.stmtHits, 1)
 		return s, true
 	}
 	db.mux.RUnlock()
 	return nil, false
 }
 
-func (db *DB) prepareContext(ctx context.Context, uid string, qry string) *Stmt {
+// prepareContext returns cached statement or prepares it. Pinned statement
+// is never evicted from the cache.
+func (db *DB) prepareContext(ctx context.Context, uid, qry, table string, pinned bool) *Stmt {
 
 	db.mux.RLock()
 	s, ok := db.ps[uid]
 	if ok {
 		db.mux.RUnlock()
+		atomic.AddUint64(&db.stmtHits, 1)
 		return s
 	}
 
 	db.mux.RUnlock()
+	atomic.AddUint64(&db.stmtMisses, 1)
 
 	qry = strings.Trim(qry, "\n\t")
 
-	stmt := newStmt(ctx, db, uid, qry)
+	// statement executed with not static SQL comment is sent unprepared,
+	// it's prepared on the first execution without such comment.
+	prepare := db.execModeOf(ctx) != ExecUnprepared && db.sqlComment(ctx) == db.staticSQLComment()
+	stmt := newStmt(ctx, db, uid, qry, table, pinned, prepare)
 	if stmt.Err() != nil {
 		return stmt
 	}
 
 	db.mux.Lock()
-	db.ps[uid] = stmt
+	if s, ok := db.ps[uid]; ok {
+		// prepared concurrently.
+		db.mux.Unlock()
+		_ = stmt.closeSQL()
+		return s
+	}
+	evicted := db.cacheStmt(stmt)
 	db.mux.Unlock()
 
+	db.evict(evicted)
 	return stmt
 }
 
-func (db *DB) delStmt(uid string) {
+func (db *DB) delStmt(s *Stmt) {
 	db.mux.Lock()
-	delete(db.ps, uid)
+	db.uncacheStmt(s)
 	db.mux.Unlock()
 }
 
@@ -267,19 +349,59 @@ func (db *DB) Logger() *zerolog.Logger {
 	return &db.logger
 }
 
+// fmtArgs returns query parameters formatted like "$1=10;$2=abc".
+// Pointers are dereferenced, nil values are formatted as NULL.
 func fmtArgs(args ...interface{}) string {
 
 	var s, sep string
 	for i := range args {
-		s += sep + "$" + strconv.Itoa(i+1) + "="
-		fmt.Sprintf("%s", args[i])
+		s += sep + "$" + strconv.Itoa(i+1) + "=" + fmtArg(args[i])
 		sep = ";"
 	}
 	return s
 }
 
+func fmtArg(arg interface{}) string {
+	v := reflect.ValueOf(arg)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "NULL"
+		}
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		return "NULL"
+	}
+
+	arg = v.Interface()
+	if dv, ok := arg.(driver.Valuer); ok {
+		val, err := dv.Value()
+		if err != nil {
+			return "!" + err.Error()
+		}
+		if val == nil {
+			return "NULL"
+		}
+		arg = val
+	}
+
+	if b, ok := arg.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprintf("%v", arg)
+}
+
+// fmtContext returns context related parameters formatted like "deadline=1.5s".
 func fmtContext(ctx context.Context) string {
-	return "context=example;a=b;c=d"
+	if ctx == nil {
+		return ""
+	}
+
+	if dl, ok := ctx.Deadline(); ok {
+		return "deadline=" + time.Until(dl).String()
+	}
+	return ""
 }
 
 func (db *DB) PreparedStatementCount() int {
@@ -289,32 +411,34 @@ func (db *DB) PreparedStatementCount() int {
 	return cnt
 }
 
-// CleanUnusedStatement deletes prepared statements not used last d.
+// CleanUnusedStatement evicts prepared statements not used last d.
+// Statements prepared with user defined uid are kept.
 func (db *DB) CleanUnusedStatement(d time.Duration) {
 
 	var arr []*Stmt
-	db.mux.RLock()
+	now := time.Now().UnixNano()
+
+	db.mux.Lock()
 	for _, s := range db.ps {
-		arr = append(arr, s)
+		if !s.pinned && now-s.LastInstanceUnixNano() > d.Nanoseconds() {
+			arr = append(arr, s)
+		}
 	}
-	db.mux.RUnlock()
-
-	now := time.Now().Unix()
-
 	for i := range arr {
-		if now-arr[i].LastInstanceUnixTime() > d.Nanoseconds() {
-			db.delStmt(arr[i].UID())
-			_ = arr[i].Close()
-		}
+		db.uncacheStmt(arr[i])
 	}
-	return
+	db.mux.Unlock()
+
+	db.evict(arr)
 }
 
 func (db *DB) Query(qry string, args ...interface{}) *StmtInstance {
+	qry, args = db.expandSlices(context.Background(), qry, args)
 	return db.Prepare(qry).Instance().Query(args...)
 }
 
 func (db *DB) QueryContext(ctx context.Context, qry string, args ...interface{}) *StmtInstance {
+	qry, args = db.expandSlices(ctx, qry, args)
 	return db.PrepareContext(ctx, qry).Instance().QueryContext(ctx, args...)
 }
 
@@ -323,38 +447,47 @@ func (db *DB) QueryRow(qry string, args ...interface{}) *StmtInstance {
 }
 
 func (db *DB) QueryRowContext(ctx context.Context, qry string, args ...interface{}) *StmtInstance {
+	qry, args = db.expandSlices(ctx, qry, args)
 	return db.PrepareContext(ctx, qry).Instance().QueryRowContext(ctx, args...)
 }
 
 func (db *DB) Exec(qry string, args ...interface{}) (sql.Result, error) {
+	qry, args = db.expandSlices(context.Background(), qry, args)
 	return db.PrepareContext(context.Background(), qry).Instance().Debug().Exec(args...)
 }
 
 func (db *DB) ExecContext(ctx context.Context, qry string, args ...interface{}) (sql.Result, error) {
+	qry, args = db.expandSlices(ctx, qry, args)
 	return db.PrepareContext(ctx, qry).Instance().ExecContext(ctx, args...)
 }
 
 func (db *DB) QueryTx(tx *Tx, qry string, args ...interface{}) *StmtInstance {
+	qry, args = db.expandSlices(context.Background(), qry, args)
 	return db.Prepare(qry).InstanceTx(tx).Query(args...)
 }
 
 func (db *DB) QueryContextTx(ctx context.Context, tx *Tx, qry string, args ...interface{}) *StmtInstance {
+	qry, args = db.expandSlices(ctx, qry, args)
 	return db.PrepareContext(ctx, qry).InstanceTx(tx).QueryContext(ctx, args...)
 }
 
 func (db *DB) QueryRowTx(tx *Tx, qry string, args ...interface{}) *StmtInstance {
+	qry, args = db.expandSlices(context.Background(), qry, args)
 	return db.Prepare(qry).InstanceTx(tx).QueryRow(args...)
 }
 
 func (db *DB) QueryRowContextTx(ctx context.Context, tx *Tx, qry string, args ...interface{}) *StmtInstance {
+	qry, args = db.expandSlices(ctx, qry, args)
 	return db.PrepareContext(ctx, qry).InstanceTx(tx).QueryRowContext(ctx, args...)
 }
 
 func (db *DB) ExecTx(tx *Tx, qry string, args ...interface{}) (sql.Result, error) {
+	qry, args = db.expandSlices(context.Background(), qry, args)
 	return db.PrepareContext(context.Background(), qry).InstanceTx(tx).Exec(args...)
 }
 
 func (db *DB) ExecContextTx(ctx context.Context, tx *Tx, qry string, args ...interface{}) (sql.Result, error) {
+	qry, args = db.expandSlices(ctx, qry, args)
 	return db.PrepareContext(ctx, qry).InstanceTx(tx).ExecContext(ctx, args...)
 }
 
@@ -366,6 +499,8 @@ func (db *DB) PlaceHolderType() ParamPlaceHolderType {
 	return db.paramPlaceHolder
 }
 
+// InTx calls f in a new transaction, committing it if f returns nil and
+// rolling it back otherwise. Use Tx.InTx to run f inside existing transaction.
 func (db *DB) InTx(f func(*Tx) error) error {
 	tx := db.Begin()
 	if err := tx.Err(); err != nil {
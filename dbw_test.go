@@ -1,6 +1,8 @@
 package dbw
 
 import (
+	"context"
+	"sync"
 	"testing"
 	"time"
 )
@@ -126,3 +128,50 @@ func TestFieldNames(t *testing.T) {
 
 	t.Logf("version after: %v", row.RowVersion)
 }
+
+func TestFmtArgs(t *testing.T) {
+
+	s := "Robert"
+	var np *string
+	nt := NullTime{}
+
+	res := fmtArgs(10, &s, np, nt, []byte("abc"), nil)
+	expected := "$1=10;$2=Robert;$3=NULL;$4=NULL;$5=abc;$6=NULL"
+	if res != expected {
+		t.Errorf("expected %s, got %s", expected, res)
+	}
+}
+
+func TestDB_SettersConcurrent(t *testing.T) {
+
+	db, _ := newFakeDB(t, nil)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			db.SetStmtLogger(&recStmtLogger{})
+			db.SetTracer(&testTracer{})
+			db.SetRedactor(NewRedactor())
+			db.SetSliceMode(SliceMode(i%2 + 1))
+			db.SetReprepareInTx(i%2 == 0)
+			db.SetExecMode(ExecMode(i%2 + 1))
+			db.SetStmtCacheSize(i % 3)
+			db.SetCommentTag("service", StaticTag("billing"))
+			db.SetSlowQueryLog(time.Duration(i%2) * time.Hour)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			if _, err := db.ExecContext(ctx, "UPDATE t SET a = $1", i); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,11 @@
+module github.com/axkit/dbw/dbwotel
+
+go 1.16
+
+require (
+	github.com/axkit/dbw v0.0.0-20261018201447-a7ae8c5a1e6b
+	github.com/axkit/errors v0.2.3
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/sdk v1.7.0
+	go.opentelemetry.io/otel/trace v1.7.0
+)
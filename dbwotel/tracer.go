@@ -0,0 +1,78 @@
+// Package dbwotel implements dbw.Tracer using OpenTelemetry. The package is
+// a separate module, dbw itself does not depend on OpenTelemetry.
+//
+//	db.SetTracer(dbwotel.New(nil))
+//
+// The module requires a published version of dbw. To build it against the
+// working tree of dbw use a workspace, it's not committed:
+//
+//	go work init . ./dbwotel
+package dbwotel
+
+import (
+	"context"
+
+	"github.com/axkit/dbw"
+	"github.com/axkit/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/axkit/dbw"
+
+// Tracer implements dbw.Tracer.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New returns tracer creating spans by tp. Global tracer provider is used if tp is nil.
+func New(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+// Start starts client span named like "dbw.query" or "dbw.query customers".
+func (t *Tracer) Start(ctx context.Context, info dbw.SpanInfo) (context.Context, dbw.Span) {
+
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation", info.Op),
+	}
+
+	name := "dbw." + info.Op
+	if info.Table != "" {
+		name += " " + info.Table
+		attrs = append(attrs, attribute.String("db.sql.table", info.Table))
+	}
+	if info.SQL != "" {
+		attrs = append(attrs, attribute.String("db.statement", info.SQL))
+	}
+	if info.UID != "" {
+		attrs = append(attrs, attribute.String("dbw.stmt.uid", info.UID))
+	}
+	if info.TxID > 0 {
+		attrs = append(attrs, attribute.Int64("dbw.tx.id", int64(info.TxID)))
+	}
+
+	ctx, s := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, &span{s: s}
+}
+
+type span struct {
+	s trace.Span
+}
+
+// End records rows and error and finishes the span. Not found results are
+// not recorded as errors.
+func (s *span) End(rows int, err error) {
+	s.s.SetAttributes(attribute.Int("db.rows", rows))
+	if err != nil && !errors.IsNotFound(err) {
+		s.s.RecordError(err)
+		s.s.SetStatus(codes.Error, err.Error())
+	}
+	s.s.End()
+}
@@ -0,0 +1,105 @@
+package dbwotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/axkit/dbw"
+	"github.com/axkit/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newRecorder() (*Tracer, *tracetest.SpanRecorder) {
+	sr := tracetest.NewSpanRecorder()
+	return New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))), sr
+}
+
+func TestTracer_Start(t *testing.T) {
+
+	tr, sr := newRecorder()
+
+	_, s := tr.Start(context.Background(), dbw.SpanInfo{
+		Op:    dbw.SpanQuery,
+		UID:   "customers.select",
+		SQL:   "SELECT id FROM customers",
+		Table: "customers",
+		TxID:  7,
+	})
+	s.End(3, nil)
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+
+	rs := spans[0]
+	if exp := "dbw." + dbw.SpanQuery + " customers"; rs.Name() != exp {
+		t.Errorf("expected span name %q, got %q", exp, rs.Name())
+	}
+	if rs.SpanKind() != trace.SpanKindClient {
+		t.Errorf("expected client span, got %v", rs.SpanKind())
+	}
+
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range rs.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+
+	exp := map[attribute.Key]attribute.Value{
+		"db.system":    attribute.StringValue("postgresql"),
+		"db.operation": attribute.StringValue(dbw.SpanQuery),
+		"db.sql.table": attribute.StringValue("customers"),
+		"db.statement": attribute.StringValue("SELECT id FROM customers"),
+		"dbw.stmt.uid": attribute.StringValue("customers.select"),
+		"dbw.tx.id":    attribute.Int64Value(7),
+		"db.rows":      attribute.IntValue(3),
+	}
+	for k, v := range exp {
+		if attrs[k] != v {
+			t.Errorf("attribute %s: expected %v, got %v", k, v.Emit(), attrs[k].Emit())
+		}
+	}
+
+	if rs.Status().Code != codes.Unset {
+		t.Errorf("expected unset status, got %v", rs.Status().Code)
+	}
+}
+
+func TestSpan_End(t *testing.T) {
+
+	tc := []struct {
+		name   string
+		err    error
+		status codes.Code
+		events int
+	}{
+		{"ok", nil, codes.Unset, 0},
+		{"failed", errors.New("query failed"), codes.Error, 1},
+		{"not-found", errors.NotFound("row not found"), codes.Unset, 0},
+	}
+
+	for i := range tc {
+		tr, sr := newRecorder()
+
+		_, s := tr.Start(context.Background(), dbw.SpanInfo{Op: dbw.SpanExec})
+		s.End(0, tc[i].err)
+
+		rs := sr.Ended()[0]
+		if rs.Name() != "dbw."+dbw.SpanExec {
+			t.Errorf("%s: unexpected span name %q", tc[i].name, rs.Name())
+		}
+		if rs.Status().Code != tc[i].status {
+			t.Errorf("%s: expected status %v, got %v", tc[i].name, tc[i].status, rs.Status().Code)
+		}
+		if tc[i].err != nil && tc[i].status == codes.Error && rs.Status().Description != tc[i].err.Error() {
+			t.Errorf("%s: expected status description %q, got %q", tc[i].name, tc[i].err.Error(), rs.Status().Description)
+		}
+		if len(rs.Events()) != tc[i].events {
+			t.Errorf("%s: expected %d events, got %d", tc[i].name, tc[i].events, len(rs.Events()))
+		}
+	}
+}
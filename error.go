@@ -17,6 +17,7 @@ var (
 	ErrConnectionDone          = errors.New("database connection lost").StatusCode(503).Critical()
 	ErrQueryExecFailed         = errors.New("query execution failed").StatusCode(500).Critical()
 	ErrNotFound                = errors.New("not found").StatusCode(404)
+	ErrLockNotAvailable        = errors.New("lock not available").StatusCode(409)
 )
 
 type targetType string
@@ -62,9 +63,10 @@ func (err Error) MarshalZerologObject(e *zerolog.Event) {
 	e.Str("sql", err.sqlQuery).Str("sqlerrcode", err.sqlErrCode).Str("sqlerrmsg", err.Error())
 
 	if len(err.params) > 0 {
+		params := DefaultRedactor.Redact(err.sqlQuery, err.params)
 		s := "["
-		for i := range err.params {
-			s += fmt.Sprintf("%v,", err.params[i])
+		for i := range params {
+			s += fmt.Sprintf("%v,", params[i])
 		}
 		s += "]"
 		e.Str("params", s)
@@ -78,14 +80,22 @@ func WrapError(t interface{}, err error, params ...interface{}) error {
 
 	ce := errors.Catch(err).Severity(errors.Critical)
 
+	var db *DB
+	qry := ""
+
 	switch t.(type) {
 	case *string:
+		qry = *t.(*string)
 		ce.Set("target", string(targetQuery))
-		ce.Set("sql", *t.(*string))
+		ce.Set("sql", qry)
 	case string:
+		qry = t.(string)
 		ce.Set("target", string(targetQuery))
-		ce.Set("sql", t.(string))
+		ce.Set("sql", qry)
 	case *Table:
+		// params belong to the failed statement of the table.
+		db = t.(*Table).db
+		qry, _ = ce.GetDefault("query", "").(string)
 		ce.Set("target", string(targetTable))
 		ce.Set("sql", t.(*Table).name)
 	default:
@@ -103,7 +113,7 @@ func WrapError(t interface{}, err error, params ...interface{}) error {
 		}
 	}
 	if len(params) > 0 {
-		ce.SetVals("params", params...)
+		ce.SetVals("params", db.redact(qry, params)...)
 	}
 
 	//e.params = append(e.params, params...)
@@ -145,6 +155,46 @@ func (e *Error) SQLErrCode() string {
 	return e.sqlErrCode
 }
 
+// SQLState returns PostgreSQL error code (SQLSTATE) of err or
+// empty string if err is not raised by PostgreSQL.
+func SQLState(err error) string {
+	if ce, ok := err.(*errors.CatchedError); ok {
+		if code, ok := ce.Get("pgCode"); ok {
+			s, _ := code.(string)
+			return s
+		}
+	}
+
+	if pge := pqError(err); pge != nil {
+		return string(pge.Code)
+	}
+	return ""
+}
+
+// pqError returns PostgreSQL error holding by err or nil.
+func pqError(err error) *pq.Error {
+	switch e := err.(type) {
+	case *pq.Error:
+		return e
+	case *errors.CatchedError:
+		for _, we := range e.WrappedErrors() {
+			if pge, ok := we.Err().(*pq.Error); ok {
+				return pge
+			}
+		}
+	}
+	return nil
+}
+
+// SQLStateClass returns class of PostgreSQL error code (first two
+// characters of SQLSTATE) of err or empty string.
+func SQLStateClass(err error) string {
+	if s := SQLState(err); len(s) >= 2 {
+		return s[:2]
+	}
+	return ""
+}
+
 func parseError(err error) error {
 	if err == nil {
 		return nil
@@ -191,6 +241,8 @@ func parseError(err error) error {
 			ce = errors.Wrap(pge, ErrUniqueViolation).SetPairs(kv...)
 		case "23514":
 			ce = errors.Wrap(pge, ErrCheckConstaintViolation).SetPairs(kv...)
+		case "55P03":
+			ce = errors.Wrap(pge, ErrLockNotAvailable).SetPairs(kv...)
 		default:
 			ce = errors.Wrap(pge, ErrQueryExecFailed).SetPairs(kv...)
 		}
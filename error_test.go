@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/axkit/errors"
+	"github.com/lib/pq"
 )
 
 func TestWrapError(t *testing.T) {
@@ -20,3 +21,23 @@ func TestWrapError(t *testing.T) {
 		t.Errorf("expected dbw.ErrNotFound, got another %T", ce)
 	}
 }
+
+func TestParseError_LockNotAvailable(t *testing.T) {
+
+	err := parseError(&pq.Error{Code: "55P03", Message: "could not obtain lock on row"})
+
+	if !errors.Is(err, ErrLockNotAvailable) {
+		t.Errorf("expected dbw.ErrLockNotAvailable, got %v", err)
+	}
+}
+
+func TestSQLStateClass(t *testing.T) {
+	err := parseError(&pq.Error{Code: "23505"})
+	if c := SQLStateClass(err); c != "23" {
+		t.Errorf("expected class 23, got %q", c)
+	}
+
+	if c := SQLStateClass(errors.New("failed")); c != "" {
+		t.Errorf("expected empty class, got %q", c)
+	}
+}
@@ -0,0 +1,135 @@
+package dbw
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+)
+
+// ExecMode defines how statements are sent to the database.
+type ExecMode int
+
+const (
+	// ExecDefault inherits execution mode from context or database.
+	ExecDefault ExecMode = iota
+
+	// ExecPrepared prepares statements in the database once and caches them.
+	ExecPrepared
+
+	// ExecUnprepared sends statement text with every execution. It's
+	// required behind PgBouncer in transaction pooling mode, where prepared
+	// statements are not bound to a session. Use binary_parameters=yes in
+	// the connection string to send a query with parameters in a single
+	// round trip; a query without parameters uses simple query protocol.
+	ExecUnprepared
+)
+
+type execModeKey struct{}
+
+// WithExecMode returns context holding execution mode for statements
+// executed with it.
+func WithExecMode(ctx context.Context, m ExecMode) context.Context {
+	return context.WithValue(ctx, execModeKey{}, m)
+}
+
+// SetExecMode sets default execution mode of the database. Default is
+// ExecPrepared.
+func (db *DB) SetExecMode(m ExecMode) {
+	atomic.StoreInt32(&db.execMode, int32(m))
+}
+
+// ExecMode returns default execution mode of the database.
+func (db *DB) ExecMode() ExecMode {
+	m := ExecMode(atomic.LoadInt32(&db.execMode))
+	if m == ExecDefault {
+		return ExecPrepared
+	}
+	return m
+}
+
+// execModeOf returns execution mode defined by ctx or database.
+func (db *DB) execModeOf(ctx context.Context) ExecMode {
+	if ctx != nil {
+		if m, ok := ctx.Value(execModeKey{}).(ExecMode); ok && m != ExecDefault {
+			return m
+		}
+	}
+
+	if db == nil {
+		return ExecPrepared
+	}
+	return db.ExecMode()
+}
+
+// WithExecMode sets execution mode of the instance.
+func (si *StmtInstance) WithExecMode(m ExecMode) *StmtInstance {
+	si.mode = m
+	return si
+}
+
+// Unprepared makes the instance send statement text to the database
+// instead of using prepared statement.
+func (si *StmtInstance) Unprepared() *StmtInstance {
+	return si.WithExecMode(ExecUnprepared)
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// preparedQueryer adapts prepared statement to queryer. Query text is ignored.
+type preparedQueryer struct {
+	s *sql.Stmt
+}
+
+func (p preparedQueryer) ExecContext(ctx context.Context, _ string, args ...interface{}) (sql.Result, error) {
+	return p.s.ExecContext(ctx, args...)
+}
+
+func (p preparedQueryer) QueryContext(ctx context.Context, _ string, args ...interface{}) (*sql.Rows, error) {
+	return p.s.QueryContext(ctx, args...)
+}
+
+func (p preparedQueryer) QueryRowContext(ctx context.Context, _ string, args ...interface{}) *sql.Row {
+	return p.s.QueryRowContext(ctx, args...)
+}
+
+// isUnprepared returns true if the statement shall be sent unprepared.
+func (si *StmtInstance) isUnprepared(ctx context.Context) bool {
+	mode := si.mode
+	if mode == ExecDefault {
+		mode = si.stmt.db.execModeOf(ctx)
+	}
+	return mode == ExecUnprepared || si.Comment != si.stmt.db.staticSQLComment()
+}
+
+// executor returns executor and text of the statement. The statement is
+// sent unprepared if it's required by execution mode or if SQL comment is
+// not static (see StaticTag). Otherwise the statement is prepared, if it's not prepared yet.
+func (si *StmtInstance) executor(ctx context.Context) (queryer, string, error) {
+
+	if si.isUnprepared(ctx) {
+		qry := appendComment(si.stmt.text, si.Comment)
+		if si.tx != nil {
+			return si.tx.SQLTx(), qry, nil
+		}
+		return si.stmt.db.SQLDB(), qry, nil
+	}
+
+	if si.sqlStmt == nil {
+		ss, err := si.stmt.prepared(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+
+		si.parentStmt = ss
+		if si.tx != nil {
+			ss = si.tx.SQLTx().StmtContext(ctx, ss)
+		}
+		si.sqlStmt = ss
+	}
+	return preparedQueryer{s: si.sqlStmt}, "", nil
+}
@@ -0,0 +1,102 @@
+package dbw
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDB_execModeOf(t *testing.T) {
+
+	db := &DB{}
+	ctx := context.Background()
+
+	if m := db.execModeOf(ctx); m != ExecPrepared {
+		t.Errorf("expected prepared by default, got %v", m)
+	}
+
+	db.SetExecMode(ExecUnprepared)
+	if m := db.execModeOf(ctx); m != ExecUnprepared {
+		t.Errorf("expected unprepared by database, got %v", m)
+	}
+
+	if m := db.execModeOf(WithExecMode(ctx, ExecPrepared)); m != ExecPrepared {
+		t.Errorf("expected prepared by context, got %v", m)
+	}
+}
+
+func TestStmtInstance_executor(t *testing.T) {
+
+	db := &DB{}
+	si := &StmtInstance{stmt: &Stmt{db: db, text: "SELECT 1"}}
+
+	_, qry, err := si.Unprepared().executor(context.Background())
+	if err != nil || qry != "SELECT 1" {
+		t.Errorf("unexpected executor result %q, %v", qry, err)
+	}
+}
+
+func TestDB_ExecUnprepared(t *testing.T) {
+
+	const qry = "UPDATE t SET a = $1"
+	ctx := context.Background()
+
+	var calls []fakeCall
+	db, srv := newFakeDB(t, recordCalls(&calls))
+	db.SetExecMode(ExecUnprepared)
+
+	for i := 0; i < 2; i++ {
+		if _, err := db.ExecContext(ctx, qry, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tx := db.BeginTx(ctx, nil)
+	if _, err := db.ExecContextTx(ctx, tx, qry, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit().Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := srv.Count("PREPARE "); n != 0 {
+		t.Errorf("expected no statements prepared: %v", srv.Log())
+	}
+	if len(calls) != 3 {
+		t.Fatalf("expected 3 executions, got %+v", calls)
+	}
+	for i := range calls {
+		if calls[i].Prepared || calls[i].Query != qry {
+			t.Errorf("expected unprepared %q, got %+v", qry, calls[i])
+		}
+	}
+}
+
+func TestDB_ExecModePerCall(t *testing.T) {
+
+	const qry = "UPDATE t SET a = $1"
+	ctx := context.Background()
+
+	var calls []fakeCall
+	db, srv := newFakeDB(t, recordCalls(&calls))
+
+	if _, err := db.ExecContext(WithExecMode(ctx, ExecUnprepared), qry, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Count("PREPARE "); n != 0 || calls[0].Prepared {
+		t.Errorf("expected statement sent unprepared by context: %v", srv.Log())
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := db.ExecContext(ctx, qry, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := srv.Count("PREPARE " + qry); n != 1 {
+		t.Errorf("expected statement prepared once, got %d: %v", n, srv.Log())
+	}
+	for i := 1; i < len(calls); i++ {
+		if !calls[i].Prepared {
+			t.Errorf("expected prepared execution %d, got %+v", i, calls[i])
+		}
+	}
+}
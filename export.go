@@ -0,0 +1,206 @@
+package dbw
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/axkit/errors"
+)
+
+// ExportOption describes CSV export parameters.
+type ExportOption struct {
+	delimiter rune
+	null      string
+	noHeader  bool
+}
+
+// WithCSVDelimiter sets CSV field delimiter. Default is comma.
+func WithCSVDelimiter(r rune) func(*ExportOption) {
+	return func(o *ExportOption) {
+		o.delimiter = r
+	}
+}
+
+// WithCSVNull sets representation of NULL values. Default is empty string.
+func WithCSVNull(s string) func(*ExportOption) {
+	return func(o *ExportOption) {
+		o.null = s
+	}
+}
+
+// WithoutCSVHeader disables header line with column names.
+func WithoutCSVHeader() func(*ExportOption) {
+	return func(o *ExportOption) {
+		o.noHeader = true
+	}
+}
+
+// WriteCSV writes rows of the result to w as CSV. Header line holds column
+// names. Values are written in PostgreSQL text representation, timestamps
+// in RFC 3339 format. Rows are not buffered.
+//
+// Rows are read by a regular query and formatted on the client side:
+// COPY ... TO STDOUT is not used, because lib/pq supports COPY FROM STDIN
+// only.
+func (it *Iterator) WriteCSV(w io.Writer, optFunc ...func(*ExportOption)) error {
+
+	option := ExportOption{delimiter: ','}
+	for i := range optFunc {
+		optFunc[i](&option)
+	}
+
+	defer it.Close()
+
+	if it.si.err != nil {
+		return it.si.err
+	}
+
+	if it.si.rows == nil {
+		return it.fail(errors.New("WriteCSV() requires Query() call"))
+	}
+
+	cols, err := it.si.rows.Columns()
+	if err != nil {
+		return it.fail(parseError(err))
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = option.delimiter
+
+	if !option.noHeader {
+		if err := cw.Write(cols); err != nil {
+			return it.fail(err)
+		}
+	}
+
+	vals := make([]interface{}, len(cols))
+	dests := make([]interface{}, len(cols))
+	for i := range vals {
+		dests[i] = &vals[i]
+	}
+	rec := make([]string, len(cols))
+
+	for it.Next() {
+		if err := it.Scan(dests...); err != nil {
+			return err
+		}
+
+		for i := range vals {
+			rec[i] = csvValue(vals[i], option.null)
+		}
+
+		if err := cw.Write(rec); err != nil {
+			return it.fail(err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil && it.si.err == nil {
+		return it.fail(err)
+	}
+
+	return it.Err()
+}
+
+// csvValue returns text representation of value returned by database driver.
+func csvValue(v interface{}, null string) string {
+	switch v := v.(type) {
+	case nil:
+		return null
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// WriteNDJSON writes rows of the result to w as newline-delimited JSON.
+// If iterator has model type (IterOf or Table iterators), rows are encoded
+// as the model using its JSON tags, otherwise as objects with column names
+// as keys (see FetchMaps). Rows are not buffered.
+func (it *Iterator) WriteNDJSON(w io.Writer) error {
+
+	defer it.Close()
+
+	if it.si.err != nil {
+		return it.si.err
+	}
+
+	if it.si.rows == nil {
+		return it.fail(errors.New("WriteNDJSON() requires Query() call"))
+	}
+
+	enc := json.NewEncoder(w)
+
+	if it.typ != nil {
+		for it.Next() {
+			row := it.Row()
+			if row == nil {
+				break
+			}
+			if err := enc.Encode(row); err != nil {
+				return it.fail(err)
+			}
+		}
+		return it.Err()
+	}
+
+	ms, err := newMapScanner(it.si.rows)
+	if err != nil {
+		return it.fail(parseError(err))
+	}
+
+	for it.Next() {
+		if err := it.Scan(ms.dests...); err != nil {
+			return err
+		}
+
+		row, err := ms.row()
+		if err != nil {
+			return it.fail(err)
+		}
+
+		if err := enc.Encode(row); err != nil {
+			return it.fail(err)
+		}
+	}
+
+	return it.Err()
+}
+
+// WriteCSV writes rows of the result to w as CSV. See Iterator.WriteCSV.
+func (si *StmtInstance) WriteCSV(w io.Writer, optFunc ...func(*ExportOption)) error {
+	return si.Iter().WriteCSV(w, optFunc...)
+}
+
+// WriteNDJSON writes rows of the result to w as newline-delimited JSON
+// objects with column names as keys. See Iterator.WriteNDJSON.
+func (si *StmtInstance) WriteNDJSON(w io.Writer) error {
+	return si.Iter().WriteNDJSON(w)
+}
+
+// ExportCSV writes table rows complaints with where to w as CSV with
+// default options. Use DoSelectIter(...).WriteCSV() to customise output.
+func (t *Table) ExportCSV(ctx context.Context, w io.Writer, where string, params ...interface{}) error {
+	return WrapError(t, t.DoSelectIter(ctx, where, "", 0, 0, params...).WriteCSV(w))
+}
+
+// ExportNDJSON writes table rows complaints with where to w as
+// newline-delimited JSON using JSON tags of the table model.
+func (t *Table) ExportNDJSON(ctx context.Context, w io.Writer, where string, params ...interface{}) error {
+	return WrapError(t, t.DoSelectIter(ctx, where, "", 0, 0, params...).WriteNDJSON(w))
+}
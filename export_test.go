@@ -0,0 +1,163 @@
+package dbw
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/axkit/errors"
+)
+
+func TestCSVValue(t *testing.T) {
+
+	ts := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	tc := []struct {
+		src interface{}
+		dst string
+	}{
+		{nil, `\N`},
+		{[]byte("{1,2}"), "{1,2}"},
+		{"text", "text"},
+		{ts, "2021-03-04T05:06:07Z"},
+		{int64(-5), "-5"},
+		{1.25, "1.25"},
+		{true, "true"},
+	}
+
+	for i := range tc {
+		if res := csvValue(tc[i].src, `\N`); res != tc[i].dst {
+			t.Errorf("expected %q, got %q", tc[i].dst, res)
+		}
+	}
+}
+
+// failWriter fails after n successful writes.
+type failWriter struct {
+	n   int
+	err error
+	buf bytes.Buffer
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, w.err
+	}
+	w.n--
+	return w.buf.Write(p)
+}
+
+func TestIterator_WriteNDJSONWriterError(t *testing.T) {
+
+	failed := errors.New("disk full")
+	db, srv := newFakeDB(t, iterServer(10, 0, nil))
+
+	w := &failWriter{n: 1, err: failed}
+	err := db.QueryContext(context.Background(), "SELECT id, name FROM items").WriteNDJSON(w)
+	if !errors.Is(err, failed) {
+		t.Errorf("expected writer error, got %v", err)
+	}
+	if strings.Count(w.buf.String(), "\n") != 1 {
+		t.Errorf("expected single row written, got %q", w.buf.String())
+	}
+	if !srv.allClosed() {
+		t.Error("expected rows closed on writer error")
+	}
+}
+
+func TestIterator_WriteCSVWriterError(t *testing.T) {
+
+	failed := errors.New("disk full")
+	db, srv := newFakeDB(t, iterServer(3, 0, nil))
+
+	err := db.QueryContext(context.Background(), "SELECT id, name FROM items").WriteCSV(&failWriter{err: failed})
+	if !errors.Is(err, failed) {
+		t.Errorf("expected writer error, got %v", err)
+	}
+	if !srv.allClosed() {
+		t.Error("expected rows closed on writer error")
+	}
+}
+
+func TestIterator_WriteCSVRowError(t *testing.T) {
+
+	failed := errors.New("connection reset")
+	db, _ := newFakeDB(t, iterServer(3, 2, failed))
+
+	var buf bytes.Buffer
+	err := db.QueryContext(context.Background(), "SELECT id, name FROM items").WriteCSV(&buf)
+	if !errors.Is(err, failed) {
+		t.Errorf("expected row error, got %v", err)
+	}
+}
+
+// exportServer answers every query by rows with values needing quoting.
+func exportServer(c *fakeCall) (*fakeRows, error) {
+	return &fakeRows{
+		cols: []string{"id", "name", "note"},
+		vals: [][]driver.Value{
+			{int64(1), "plain", nil},
+			{int64(2), `a,"b"`, "line\nbreak"},
+		},
+	}, nil
+}
+
+func TestIterator_WriteCSV(t *testing.T) {
+
+	db, _ := newFakeDB(t, exportServer)
+
+	tc := []struct {
+		name string
+		opts []func(*ExportOption)
+		exp  string
+	}{
+		{"default", nil, "id,name,note\n1,plain,\n2,\"a,\"\"b\"\"\",\"line\nbreak\"\n"},
+		{"options", []func(*ExportOption){WithCSVDelimiter(';'), WithCSVNull(`\N`), WithoutCSVHeader()},
+			"1;plain;\\N\n2;\"a,\"\"b\"\"\";\"line\nbreak\"\n"},
+	}
+
+	for i := range tc {
+		var buf bytes.Buffer
+		if err := db.QueryContext(context.Background(), "SELECT id, name, note FROM items").WriteCSV(&buf, tc[i].opts...); err != nil {
+			t.Fatalf("%s: %v", tc[i].name, err)
+		}
+		if buf.String() != tc[i].exp {
+			t.Errorf("%s: expected %q, got %q", tc[i].name, tc[i].exp, buf.String())
+		}
+	}
+}
+
+func TestIterator_WriteNDJSON(t *testing.T) {
+
+	db, _ := newFakeDB(t, exportServer)
+
+	var buf bytes.Buffer
+	if err := db.QueryContext(context.Background(), "SELECT id, name, note FROM items").WriteNDJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "{\"id\":1,\"name\":\"plain\",\"note\":null}\n" +
+		"{\"id\":2,\"name\":\"a,\\\"b\\\"\",\"note\":\"line\\nbreak\"}\n"
+	if buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+
+	type Item struct {
+		ID   int    `json:"item_id"`
+		Name string `json:"-"`
+		Note *string
+	}
+
+	buf.Reset()
+	if err := db.QueryContext(context.Background(), "SELECT id, name, note FROM items").IterOf(&Item{}).WriteNDJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	exp = "{\"item_id\":1,\"Note\":null}\n{\"item_id\":2,\"Note\":\"line\\nbreak\"}\n"
+	if buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+}
@@ -0,0 +1,345 @@
+package dbw
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// fakeDriver emulates PostgreSQL connection for tests: it records executed
+// statements, tracks savepoints of transactions and aborts transaction on
+// error. Statements other than transaction control are answered by
+// fakeServer.handler.
+type fakeDriver struct{}
+
+var (
+	fakeServersMux sync.Mutex
+	fakeServers    = make(map[string]*fakeServer)
+)
+
+func init() {
+	sql.Register("dbwfake", fakeDriver{})
+}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	fakeServersMux.Lock()
+	srv, ok := fakeServers[dsn]
+	fakeServersMux.Unlock()
+	if !ok {
+		return nil, errors.New("unknown fake server " + dsn)
+	}
+	return &fakeConn{srv: srv}, nil
+}
+
+// fakeCall describes statement received by fake server.
+type fakeCall struct {
+	Query    string
+	Args     []interface{}
+	Prepared bool
+}
+
+// fakeRows holds result returned by fake server. If errAt is positive,
+// err is returned instead of row number errAt.
+type fakeRows struct {
+	cols  []string
+	vals  [][]driver.Value
+	errAt int
+	err   error
+
+	// closeErr is returned by Close.
+	closeErr error
+
+	pos    int
+	closed bool
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+
+func (r *fakeRows) Close() error {
+	r.closed = true
+	return r.closeErr
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.errAt > 0 && r.pos+1 == r.errAt {
+		return r.err
+	}
+	if r.pos >= len(r.vals) {
+		return io.EOF
+	}
+	copy(dest, r.vals[r.pos])
+	r.pos++
+	return nil
+}
+
+type fakeServer struct {
+	mux sync.Mutex
+
+	// log holds executed statements. Prepared and closed statements are
+	// prefixed by "PREPARE " and "DEALLOCATE ".
+	log []string
+
+	// handler answers statements. Nil rows means empty result.
+	handler func(c *fakeCall) (*fakeRows, error)
+
+	// prepare validates prepared statements, if not nil. It's set before
+	// the first statement.
+	prepare func(qry string) error
+
+	// results holds rows returned to the client.
+	results []*fakeRows
+}
+
+// newFakeDB returns database connected to new fake server.
+func newFakeDB(t *testing.T, handler func(c *fakeCall) (*fakeRows, error)) (*DB, *fakeServer) {
+	t.Helper()
+
+	srv := &fakeServer{handler: handler}
+	dsn := t.Name()
+
+	fakeServersMux.Lock()
+	fakeServers[dsn] = srv
+	fakeServersMux.Unlock()
+
+	sqldb, err := sql.Open("dbwfake", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		sqldb.Close()
+		fakeServersMux.Lock()
+		delete(fakeServers, dsn)
+		fakeServersMux.Unlock()
+	})
+
+	db := Inherit(sqldb)
+	db.SetPlaceHolderType(DollarPlusPosition)
+	return db, srv
+}
+
+// Log returns copy of executed statements.
+func (srv *fakeServer) Log() []string {
+	srv.mux.Lock()
+	defer srv.mux.Unlock()
+	return append([]string(nil), srv.log...)
+}
+
+// Count returns number of executed statements starting with prefix.
+func (srv *fakeServer) Count(prefix string) int {
+	n := 0
+	for _, s := range srv.Log() {
+		if strings.HasPrefix(s, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// allClosed returns true if all rows returned to the client are closed.
+func (srv *fakeServer) allClosed() bool {
+	srv.mux.Lock()
+	defer srv.mux.Unlock()
+	for _, r := range srv.results {
+		if !r.closed {
+			return false
+		}
+	}
+	return true
+}
+
+func (srv *fakeServer) record(s string) {
+	srv.mux.Lock()
+	srv.log = append(srv.log, s)
+	srv.mux.Unlock()
+}
+
+type fakeConn struct {
+	srv        *fakeServer
+	inTx       bool
+	aborted    bool
+	savepoints []string
+}
+
+func (c *fakeConn) Prepare(qry string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), qry)
+}
+
+func (c *fakeConn) PrepareContext(ctx context.Context, qry string) (driver.Stmt, error) {
+	c.srv.record("PREPARE " + qry)
+	if c.aborted {
+		return nil, &pq.Error{Code: "25P02", Message: "current transaction is aborted"}
+	}
+	if c.srv.prepare != nil {
+		if err := c.srv.prepare(qry); err != nil {
+			return nil, err
+		}
+	}
+	return &fakeStmt{conn: c, qry: qry}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.srv.record("BEGIN")
+	c.inTx, c.aborted, c.savepoints = true, false, nil
+	return fakeTx{c}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, qry string, args []driver.NamedValue) (driver.Result, error) {
+	rows, err := c.run(qry, args, false)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(len(rows.vals)), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, qry string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.query(qry, args, false)
+}
+
+// run executes statements separated by "; " emulating transaction state.
+func (c *fakeConn) run(qry string, args []driver.NamedValue, prepared bool) (*fakeRows, error) {
+	var (
+		rows *fakeRows
+		err  error
+	)
+	for _, q := range strings.Split(qry, "; ") {
+		if rows, err = c.runOne(q, args, prepared); err != nil {
+			if c.inTx {
+				c.aborted = true
+			}
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// query executes statement returning rows tracked by the server.
+func (c *fakeConn) query(qry string, args []driver.NamedValue, prepared bool) (driver.Rows, error) {
+	rows, err := c.run(qry, args, prepared)
+	if err != nil {
+		return nil, err
+	}
+
+	c.srv.mux.Lock()
+	c.srv.results = append(c.srv.results, rows)
+	c.srv.mux.Unlock()
+	return rows, nil
+}
+
+func (c *fakeConn) runOne(qry string, args []driver.NamedValue, prepared bool) (*fakeRows, error) {
+	c.srv.record(qry)
+
+	unquote := func(s string) string { return strings.Trim(s, `"`) }
+
+	switch {
+	case strings.HasPrefix(qry, "ROLLBACK TO SAVEPOINT "):
+		i := c.savepointIndex(unquote(qry[len("ROLLBACK TO SAVEPOINT "):]))
+		if i < 0 {
+			return nil, &pq.Error{Code: "3B001", Message: "savepoint does not exist"}
+		}
+		c.savepoints = c.savepoints[:i+1]
+		c.aborted = false
+		return &fakeRows{}, nil
+	case c.aborted:
+		return nil, &pq.Error{Code: "25P02", Message: "current transaction is aborted"}
+	case strings.HasPrefix(qry, "SAVEPOINT "):
+		c.savepoints = append(c.savepoints, unquote(qry[len("SAVEPOINT "):]))
+		return &fakeRows{}, nil
+	case strings.HasPrefix(qry, "RELEASE SAVEPOINT "):
+		i := c.savepointIndex(unquote(qry[len("RELEASE SAVEPOINT "):]))
+		if i < 0 {
+			return nil, &pq.Error{Code: "3B001", Message: "savepoint does not exist"}
+		}
+		c.savepoints = c.savepoints[:i]
+		return &fakeRows{}, nil
+	}
+
+	call := fakeCall{Query: qry, Prepared: prepared}
+	for i := range args {
+		call.Args = append(call.Args, args[i].Value)
+	}
+
+	if c.srv.handler == nil {
+		return &fakeRows{}, nil
+	}
+
+	rows, err := c.srv.handler(&call)
+	if rows == nil && err == nil {
+		rows = &fakeRows{}
+	}
+	return rows, err
+}
+
+func (c *fakeConn) savepointIndex(name string) int {
+	for i := len(c.savepoints) - 1; i >= 0; i-- {
+		if c.savepoints[i] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+type fakeTx struct {
+	c *fakeConn
+}
+
+func (tx fakeTx) Commit() error {
+	tx.c.srv.record("COMMIT")
+	aborted := tx.c.aborted
+	tx.c.inTx, tx.c.aborted, tx.c.savepoints = false, false, nil
+	if aborted {
+		return &pq.Error{Code: "25P02", Message: "current transaction is aborted"}
+	}
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.c.srv.record("ROLLBACK")
+	tx.c.inTx, tx.c.aborted, tx.c.savepoints = false, false, nil
+	return nil
+}
+
+type fakeStmt struct {
+	conn *fakeConn
+	qry  string
+}
+
+func (s *fakeStmt) Close() error {
+	s.conn.srv.record("DEALLOCATE " + s.qry)
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, driver.ErrSkip
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, driver.ErrSkip
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	rows, err := s.conn.run(s.qry, args, true)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(len(rows.vals)), nil
+}
+
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.query(s.qry, args, true)
+}
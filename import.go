@@ -0,0 +1,565 @@
+package dbw
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/axkit/errors"
+)
+
+// ImportFormat defines format of data imported by Table.Import.
+type ImportFormat int
+
+const (
+	// ImportCSV - CSV with header line holding column names.
+	ImportCSV ImportFormat = iota
+	// ImportNDJSON - newline-delimited JSON objects with column names as keys.
+	ImportNDJSON
+)
+
+// DefaultImportBatchSize holds default amount of rows inserted by a single batch.
+const DefaultImportBatchSize = 1000
+
+// ErrImportUnmappedColumn returns by Import if CSV header has no
+// corresponding model field. NDJSON record having such key is rejected
+// with the error.
+var ErrImportUnmappedColumn = errors.New("import column not mapped to model field").StatusCode(400)
+
+// ImportOption describes Table.Import parameters.
+type ImportOption struct {
+	batchSize      int
+	txPerBatch     bool
+	delimiter      rune
+	null           string
+	ignoreUnmapped bool
+	validate       func(row interface{}) error
+}
+
+// WithImportBatchSize sets amount of rows inserted by a single batch.
+func WithImportBatchSize(n int) func(*ImportOption) {
+	return func(o *ImportOption) {
+		o.batchSize = n
+	}
+}
+
+// WithImportTxPerBatch makes Import commit every batch in a separate
+// transaction. By default all rows are inserted in a single transaction.
+func WithImportTxPerBatch() func(*ImportOption) {
+	return func(o *ImportOption) {
+		o.txPerBatch = true
+	}
+}
+
+// WithImportCSVDelimiter sets CSV field delimiter. Default is comma.
+func WithImportCSVDelimiter(r rune) func(*ImportOption) {
+	return func(o *ImportOption) {
+		o.delimiter = r
+	}
+}
+
+// WithImportCSVNull sets CSV representation of NULL. Default is empty string.
+func WithImportCSVNull(s string) func(*ImportOption) {
+	return func(o *ImportOption) {
+		o.null = s
+	}
+}
+
+// WithImportIgnoreUnmapped makes Import skip columns having no
+// corresponding model field.
+func WithImportIgnoreUnmapped() func(*ImportOption) {
+	return func(o *ImportOption) {
+		o.ignoreUnmapped = true
+	}
+}
+
+// WithImportValidator sets function validating every row before insert.
+// Row is rejected if f returns error.
+func WithImportValidator(f func(row interface{}) error) func(*ImportOption) {
+	return func(o *ImportOption) {
+		o.validate = f
+	}
+}
+
+// ImportRejection describes a record rejected by Import.
+type ImportRejection struct {
+	// Line holds line number of the record in the source, starting from 1.
+	Line int
+
+	// Reason holds human readable rejection reason.
+	Reason string
+
+	// Err holds original error. Constraint violations are parsed like
+	// errors of other Table methods (ErrUniqueViolation, etc.)
+	Err error
+}
+
+// ImportReport describes Import result.
+type ImportReport struct {
+	// Records holds amount of records read from the source.
+	Records int
+
+	// Inserted holds amount of inserted rows.
+	Inserted int
+
+	// Rejected holds records rejected because of parse, validation or
+	// constraint violation errors.
+	Rejected []ImportRejection
+}
+
+func (r *ImportReport) reject(line int, reason string, err error) {
+	r.Rejected = append(r.Rejected, ImportRejection{Line: line, Reason: reason + ": " + err.Error(), Err: err})
+}
+
+// importRecord holds a row decoded from source.
+type importRecord struct {
+	line int
+	row  interface{}
+}
+
+// Import reads records from r and inserts them into the table by batches.
+// CSV headers and JSON keys are mapped to model fields by column names.
+// Records failed to parse, validate or insert are not inserted and
+// reported in ImportReport. Returned error describes failures preventing
+// the import at all: read errors, unmapped columns, transaction failures.
+func (t *Table) Import(ctx context.Context, r io.Reader, format ImportFormat, optFunc ...func(*ImportOption)) (*ImportReport, error) {
+
+	option := ImportOption{batchSize: DefaultImportBatchSize, delimiter: ','}
+	for i := range optFunc {
+		optFunc[i](&option)
+	}
+	if option.batchSize <= 0 {
+		option.batchSize = DefaultImportBatchSize
+	}
+
+	var (
+		rep   ImportReport
+		batch []importRecord
+		tx    *Tx
+
+		// committed holds amount of rows inserted by committed transactions.
+		committed int
+	)
+
+	begin := func() error {
+		if tx != nil {
+			return nil
+		}
+		tx = t.db.BeginTx(ctx, nil)
+		return tx.Err()
+	}
+
+	commit := func() error {
+		if tx == nil {
+			return nil
+		}
+		err := tx.Commit().Err()
+		tx = nil
+		if err == nil {
+			committed = rep.Inserted
+		}
+		return err
+	}
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := begin(); err != nil {
+			return err
+		}
+		if err := t.importBatch(ctx, tx, batch, &rep); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		if option.txPerBatch {
+			return commit()
+		}
+		return nil
+	}
+
+	add := func(rec importRecord) error {
+		rep.Records++
+		if option.validate != nil {
+			if err := option.validate(rec.row); err != nil {
+				rep.reject(rec.line, "validation failed", err)
+				return nil
+			}
+		}
+		batch = append(batch, rec)
+		if len(batch) >= option.batchSize {
+			return flush()
+		}
+		return nil
+	}
+
+	var err error
+	switch format {
+	case ImportCSV:
+		err = t.importCSV(r, &option, &rep, add)
+	case ImportNDJSON:
+		err = t.importNDJSON(r, &option, &rep, add)
+	default:
+		err = errors.New("unknown import format").StatusCode(400)
+	}
+
+	if err == nil {
+		err = flush()
+	}
+
+	if err == nil {
+		err = commit()
+	}
+
+	if err != nil {
+		if tx != nil {
+			tx.Rollback()
+			rep.Inserted = committed
+		}
+		return &rep, WrapError(t, err)
+	}
+
+	return &rep, nil
+}
+
+// importBatch inserts rows in transaction tx by multi-row INSERT. If it
+// fails, rows are inserted one by one under savepoint, failed rows are
+// rolled back and reported.
+func (t *Table) importBatch(ctx context.Context, tx *Tx, batch []importRecord, rep *ImportReport) error {
+
+	const sp = "dbw_import"
+
+	if err := tx.savepoint(ctx, sp); err != nil {
+		return err
+	}
+
+	if err := t.insertRows(ctx, tx, batch); err == nil {
+		rep.Inserted += len(batch)
+		return tx.release(ctx, sp)
+	}
+
+	if err := tx.rollbackTo(ctx, sp); err != nil {
+		return err
+	}
+	if err := tx.release(ctx, sp); err != nil {
+		return err
+	}
+
+	for i := range batch {
+		if err := tx.savepoint(ctx, sp); err != nil {
+			return err
+		}
+
+		if err := t.doInsertTxCtx(ctx, tx, batch[i].row); err != nil {
+			if rerr := tx.rollbackTo(ctx, sp); rerr != nil {
+				return rerr
+			}
+			rep.reject(batch[i].line, "insert failed", parseError(err))
+			continue
+		}
+
+		if err := tx.release(ctx, sp); err != nil {
+			return err
+		}
+		rep.Inserted++
+	}
+	return nil
+}
+
+// insertRows inserts rows of batch by multi-row INSERT statements, splitting
+// the batch if number of parameters exceeds maxQueryParams.
+func (t *Table) insertRows(ctx context.Context, tx *Tx, batch []importRecord) error {
+
+	var (
+		args []interface{}
+		n    int
+	)
+
+	for i := range batch {
+		addrs := t.FieldAddrs(batch[i].row, TagNoIns, Exclude)
+		n = len(addrs)
+		args = append(args, addrs...)
+	}
+
+	if n == 0 {
+		return errors.New("model has no inserted fields").StatusCode(500)
+	}
+
+	chunk := maxQueryParams / n
+	for i := 0; i < len(batch); i += chunk {
+		rows := len(batch) - i
+		if rows > chunk {
+			rows = chunk
+		}
+
+		qry := t.genInsertBatchSQL(rows, n)
+		if _, err := t.instance(ctx, tx, qry).ExecContext(ctx, args[i*n:(i+rows)*n]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Table) importCSV(r io.Reader, option *ImportOption, rep *ImportReport, add func(importRecord) error) error {
+
+	cr := csv.NewReader(r)
+	cr.Comma = option.delimiter
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return err
+	}
+	// the record is reused by subsequent reads.
+	header = append([]string(nil), header...)
+
+	idx, err := t.importColumns(header, option.ignoreUnmapped)
+	if err != nil {
+		return err
+	}
+
+	typ := reflect.TypeOf(t.model).Elem()
+	line := 1
+
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		line++
+
+		if err != nil {
+			// the reader continues from the next record after a parse error.
+			if pe, ok := err.(*csv.ParseError); ok {
+				line = pe.Line
+				rep.Records++
+				rep.reject(line, "parse failed", err)
+				continue
+			}
+			return err
+		}
+
+		row := reflect.New(typ)
+		var ferr error
+		for i := range rec {
+			if idx[i] == nil {
+				continue
+			}
+			if rec[i] == option.null {
+				continue
+			}
+			if ferr = setFieldString(fieldByIndexAlloc(row.Elem(), idx[i]), rec[i]); ferr != nil {
+				ferr = errors.Catch(ferr).Set("column", header[i]).Msg("column " + header[i])
+				break
+			}
+		}
+
+		if ferr != nil {
+			rep.Records++
+			rep.reject(line, "parse failed", ferr)
+			continue
+		}
+
+		if err := add(importRecord{line: line, row: row.Interface()}); err != nil {
+			return err
+		}
+	}
+}
+
+func (t *Table) importNDJSON(r io.Reader, option *ImportOption, rep *ImportReport, add func(importRecord) error) error {
+
+	fields := t.importFields()
+	typ := reflect.TypeOf(t.model).Elem()
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+
+	for sc.Scan() {
+		line++
+
+		b := bytes.TrimSpace(sc.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(b, &obj); err != nil {
+			rep.Records++
+			rep.reject(line, "parse failed", err)
+			continue
+		}
+
+		row := reflect.New(typ)
+		var ferr error
+		for k, v := range obj {
+			idx, ok := fields[strings.ToLower(k)]
+			if !ok {
+				if option.ignoreUnmapped {
+					continue
+				}
+				ferr = ErrImportUnmappedColumn.Capture().Set("column", k)
+				break
+			}
+			if ferr = json.Unmarshal(v, fieldByIndexAlloc(row.Elem(), idx).Addr().Interface()); ferr != nil {
+				ferr = errors.Catch(ferr).Set("column", k).Msg("column " + k)
+				break
+			}
+		}
+
+		if ferr != nil {
+			rep.Records++
+			rep.reject(line, "parse failed", ferr)
+			continue
+		}
+
+		if err := add(importRecord{line: line, row: row.Interface()}); err != nil {
+			return err
+		}
+	}
+
+	return sc.Err()
+}
+
+// importFields returns model field index paths by column name. Column
+// names are taken from coltag: value of tag key "col" or snake case name.
+func (t *Table) importFields() map[string][]int {
+
+	typ := reflect.TypeOf(t.model).Elem()
+	res := make(map[string][]int, len(t.coltag))
+
+	for fn, tags := range t.coltag {
+		if _, ok := tags["-"]; ok {
+			continue
+		}
+
+		sf, ok := typ.FieldByName(fn)
+		if !ok || sf.Anonymous || sf.PkgPath != "" {
+			continue
+		}
+
+		name := tags[TagCol]
+		if name == "" {
+			name = tags["SnakeName"]
+		}
+		res[name] = sf.Index
+	}
+	return res
+}
+
+// importColumns returns model field index paths for CSV header.
+func (t *Table) importColumns(header []string, ignoreUnmapped bool) ([][]int, error) {
+
+	fields := t.importFields()
+	res := make([][]int, len(header))
+
+	for i := range header {
+		idx, ok := fields[strings.ToLower(strings.TrimSpace(header[i]))]
+		if !ok {
+			if ignoreUnmapped {
+				continue
+			}
+			return nil, ErrImportUnmappedColumn.Capture().Set("column", header[i])
+		}
+		res[i] = idx
+	}
+	return res, nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setFieldString converts text s to type of field v and assigns it.
+func setFieldString(v reflect.Value, s string) error {
+
+	if v.Kind() == reflect.Ptr {
+		p := reflect.New(v.Type().Elem())
+		if err := setFieldString(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+
+	switch v.Addr().Interface().(type) {
+	case *time.Time, *NullTime:
+		tm, err := parseTime(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(tm).Convert(v.Type()))
+		return nil
+	case *NullString:
+		v.SetString(s)
+		return nil
+	}
+
+	if v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return json.Unmarshal([]byte(s), v.Addr().Interface())
+		}
+		v.SetBytes([]byte(s))
+	default:
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+	return nil
+}
+
+var importTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+func parseTime(s string) (time.Time, error) {
+	var (
+		tm  time.Time
+		err error
+	)
+	for _, l := range importTimeLayouts {
+		if tm, err = time.Parse(l, s); err == nil {
+			return tm, nil
+		}
+	}
+	return tm, err
+}
@@ -0,0 +1,277 @@
+package dbw
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/axkit/errors"
+	"github.com/lib/pq"
+)
+
+func TestTable_importColumns(t *testing.T) {
+
+	type Row struct {
+		ID        int
+		Name      string `dbw:"col=title"`
+		Amount    *float64
+		CreatedAt NullTime
+		Secret    string `dbw:"-"`
+	}
+
+	tbl := NewTable(&DB{}, "x", &Row{})
+
+	header := []string{"id", "title", "amount", "created_at"}
+	idx, err := tbl.importColumns(header, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row := reflect.New(reflect.TypeOf(Row{}))
+	rec := []string{"15", "Robert", "10.5", "2021-03-04"}
+	for i := range rec {
+		if err := setFieldString(fieldByIndexAlloc(row.Elem(), idx[i]), rec[i]); err != nil {
+			t.Fatalf("column %s: %v", header[i], err)
+		}
+	}
+
+	r := row.Interface().(*Row)
+	if r.ID != 15 || r.Name != "Robert" || r.Amount == nil || *r.Amount != 10.5 {
+		t.Errorf("unexpected row %+v", r)
+	}
+
+	if !time.Time(r.CreatedAt).Equal(time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected created_at %v", r.CreatedAt)
+	}
+
+	if _, err := tbl.importColumns([]string{"id", "secret"}, false); err == nil {
+		t.Error("expected unmapped column error")
+	}
+
+	if err := setFieldString(row.Elem().Field(0), "abc"); err == nil {
+		t.Error("expected parse error")
+	}
+}
+
+type importItem struct {
+	ID   int
+	Name string
+}
+
+// importServer returns handler failing inserts of rows with name "dup"
+// by unique violation and recording executed inserts into inserts.
+func importServer(inserts *[]fakeCall) func(c *fakeCall) (*fakeRows, error) {
+	return func(c *fakeCall) (*fakeRows, error) {
+		if !strings.HasPrefix(c.Query, "INSERT") {
+			return nil, nil
+		}
+		*inserts = append(*inserts, *c)
+		for _, a := range c.Args {
+			if a == "dup" {
+				return nil, &pq.Error{Code: "23505", Message: "duplicate key value", Constraint: "items_name_key"}
+			}
+		}
+		return nil, nil
+	}
+}
+
+func TestTable_genInsertBatchSQL(t *testing.T) {
+
+	db, _ := newFakeDB(t, nil)
+	tbl := NewTable(db, "items", &importItem{})
+
+	n := len(tbl.FieldAddrs(&importItem{}, TagNoIns, Exclude))
+	qry := tbl.genInsertBatchSQL(2, n)
+
+	single := strings.TrimSuffix(tbl.SQL.BasicInsert, ")")
+	single = strings.Replace(single, "VALUES(", "VALUES (", 1)
+	if !strings.HasPrefix(qry, single+"), (") {
+		t.Errorf("expected rows like %q, got %q", tbl.SQL.BasicInsert, qry)
+	}
+	if strings.Count(qry, "$") != 2*n || !strings.Contains(qry, "$"+strconv.Itoa(2*n)+")") {
+		t.Errorf("unexpected placeholders %q", qry)
+	}
+}
+
+func TestTable_importBatch(t *testing.T) {
+
+	var inserts []fakeCall
+	db, srv := newFakeDB(t, importServer(&inserts))
+	tbl := NewTable(db, "items", &importItem{})
+
+	batch := []importRecord{
+		{line: 2, row: &importItem{Name: "a"}},
+		{line: 3, row: &importItem{Name: "b"}},
+		{line: 4, row: &importItem{Name: "c"}},
+	}
+
+	var rep ImportReport
+	tx := db.Begin()
+	if err := tbl.importBatch(context.Background(), tx, batch, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit().Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if rep.Inserted != 3 || len(rep.Rejected) != 0 {
+		t.Errorf("unexpected report %+v", rep)
+	}
+	n := len(tbl.FieldAddrs(&importItem{}, TagNoIns, Exclude))
+	if len(inserts) != 1 || len(inserts[0].Args) != 3*n || inserts[0].Query != tbl.genInsertBatchSQL(3, n) {
+		t.Errorf("expected single multi-row insert, got %v", inserts)
+	}
+	if n := srv.Count(`SAVEPOINT "dbw_import"`); n != 1 {
+		t.Errorf("expected single savepoint, got %d", n)
+	}
+}
+
+func TestTable_importBatchFallback(t *testing.T) {
+
+	var inserts []fakeCall
+	db, _ := newFakeDB(t, importServer(&inserts))
+	tbl := NewTable(db, "items", &importItem{})
+
+	batch := []importRecord{
+		{line: 2, row: &importItem{Name: "a"}},
+		{line: 3, row: &importItem{Name: "dup"}},
+		{line: 4, row: &importItem{Name: "c"}},
+	}
+
+	var rep ImportReport
+	tx := db.Begin()
+	if err := tbl.importBatch(context.Background(), tx, batch, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit().Err(); err != nil {
+		t.Fatalf("expected transaction usable after fallback, got %v", err)
+	}
+
+	if rep.Inserted != 2 {
+		t.Errorf("expected 2 inserted rows, got %d", rep.Inserted)
+	}
+	if len(rep.Rejected) != 1 || rep.Rejected[0].Line != 3 {
+		t.Fatalf("expected line 3 rejected, got %+v", rep.Rejected)
+	}
+	if !errors.Is(rep.Rejected[0].Err, ErrUniqueViolation) {
+		t.Errorf("expected unique violation, got %v", rep.Rejected[0].Err)
+	}
+
+	// multi-row insert and one insert per row.
+	if len(inserts) != 4 {
+		t.Errorf("expected 4 inserts, got %d", len(inserts))
+	}
+}
+
+func TestTable_Import(t *testing.T) {
+
+	var inserts []fakeCall
+	db, srv := newFakeDB(t, importServer(&inserts))
+	tbl := NewTable(db, "items", &importItem{})
+
+	src := "id,name\n1,a\nx,b\n3,dup\n4,skip\n5,e\n6\n"
+	rep, err := tbl.Import(context.Background(), strings.NewReader(src), ImportCSV,
+		WithImportBatchSize(2),
+		WithImportValidator(func(row interface{}) error {
+			if row.(*importItem).Name == "skip" {
+				return errors.New("skipped")
+			}
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rep.Records != 6 || rep.Inserted != 2 {
+		t.Errorf("unexpected report %+v", rep)
+	}
+
+	lines := make([]int, len(rep.Rejected))
+	reasons := make([]string, len(rep.Rejected))
+	for i, r := range rep.Rejected {
+		lines[i] = r.Line
+		reasons[i] = strings.SplitN(r.Reason, ":", 2)[0]
+	}
+	sort.Ints(lines)
+	if !reflect.DeepEqual(lines, []int{3, 4, 5, 7}) {
+		t.Errorf("unexpected rejected lines %v: %v", lines, rep.Rejected)
+	}
+	for _, exp := range []string{"parse failed", "insert failed", "validation failed"} {
+		found := false
+		for _, r := range reasons {
+			found = found || r == exp
+		}
+		if !found {
+			t.Errorf("expected rejection %q, got %v", exp, reasons)
+		}
+	}
+
+	if srv.Count("COMMIT") != 1 {
+		t.Errorf("expected single transaction: %v", srv.Log())
+	}
+}
+
+// rejectedLines returns sorted lines of rejected records.
+func rejectedLines(rep *ImportReport) []int {
+	res := make([]int, len(rep.Rejected))
+	for i, r := range rep.Rejected {
+		res[i] = r.Line
+	}
+	sort.Ints(res)
+	return res
+}
+
+func TestTable_ImportMalformedCSV(t *testing.T) {
+
+	var inserts []fakeCall
+	db, _ := newFakeDB(t, importServer(&inserts))
+	tbl := NewTable(db, "items", &importItem{})
+
+	src := "id,name\n1,a\n2,b\"x\n3,\"c\"d\n4,e\n"
+	rep, err := tbl.Import(context.Background(), strings.NewReader(src), ImportCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rep.Records != 4 || rep.Inserted != 2 {
+		t.Errorf("unexpected report %+v", rep)
+	}
+	if lines := rejectedLines(rep); !reflect.DeepEqual(lines, []int{3, 4}) {
+		t.Errorf("unexpected rejected lines %v: %v", lines, rep.Rejected)
+	}
+}
+
+func TestTable_ImportNDJSON(t *testing.T) {
+
+	var inserts []fakeCall
+	db, _ := newFakeDB(t, importServer(&inserts))
+	tbl := NewTable(db, "items", &importItem{})
+
+	src := `{"id":1,"name":"a"}
+{"id":2,"name":"b","color":"red"}
+{"id":3,"name":"c"}
+`
+	rep, err := tbl.Import(context.Background(), strings.NewReader(src), ImportNDJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rep.Records != 3 || rep.Inserted != 2 {
+		t.Errorf("unexpected report %+v", rep)
+	}
+	if lines := rejectedLines(rep); !reflect.DeepEqual(lines, []int{2}) || !errors.Is(rep.Rejected[0].Err, ErrImportUnmappedColumn) {
+		t.Errorf("expected unmapped column rejected at line 2, got %v", rep.Rejected)
+	}
+
+	rep, err = tbl.Import(context.Background(), strings.NewReader(src), ImportNDJSON, WithImportIgnoreUnmapped())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Records != 3 || rep.Inserted != 3 || len(rep.Rejected) != 0 {
+		t.Errorf("expected unmapped column ignored, got %+v", rep)
+	}
+}
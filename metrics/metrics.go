@@ -0,0 +1,184 @@
+// Package metrics exposes statistics of dbw.DB in Prometheus text
+// exposition format without dependency on Prometheus client library.
+//
+//	http.Handle("/metrics", metrics.Handler(db))
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/axkit/dbw"
+)
+
+// DefaultNamespace holds default prefix of metric names.
+const DefaultNamespace = "dbw"
+
+// HandlerOption describes metrics handler parameters.
+type HandlerOption struct {
+	namespace string
+	labels    string
+}
+
+// WithNamespace sets prefix of metric names. Default is DefaultNamespace.
+func WithNamespace(ns string) func(*HandlerOption) {
+	return func(o *HandlerOption) {
+		o.namespace = ns
+	}
+}
+
+// WithConstLabel adds label with constant value to all metrics. It's
+// useful to distinguish several databases of the same application.
+func WithConstLabel(name, value string) func(*HandlerOption) {
+	return func(o *HandlerOption) {
+		o.labels += label(name, value)
+	}
+}
+
+type handler struct {
+	db *dbw.DB
+	HandlerOption
+}
+
+// Handler returns http.Handler writing statistics of db in Prometheus text format.
+func Handler(db *dbw.DB, optFunc ...func(*HandlerOption)) http.Handler {
+	h := &handler{db: db, HandlerOption: HandlerOption{namespace: DefaultNamespace}}
+	for i := range optFunc {
+		optFunc[i](&h.HandlerOption)
+	}
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = h.Write(w)
+}
+
+// Write writes statistics of the database to w in Prometheus text format.
+func (h *handler) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	e := encoder{w: bw, ns: h.namespace, labels: h.labels}
+	e.stats(h.db.StatementStats())
+	return bw.Flush()
+}
+
+// WriteText writes statistics of db to w in Prometheus text format.
+func WriteText(w io.Writer, db *dbw.DB, optFunc ...func(*HandlerOption)) error {
+	return Handler(db, optFunc...).(*handler).Write(w)
+}
+
+type encoder struct {
+	w      *bufio.Writer
+	ns     string
+	labels string
+}
+
+func (e *encoder) stats(s *dbw.Stats) {
+
+	p := s.Pool
+	e.gauge("pool_max_open_connections", "Maximum number of open connections to the database.", float64(p.MaxOpenConnections))
+	e.gauge("pool_open_connections", "The number of established connections both in use and idle.", float64(p.OpenConnections))
+	e.gauge("pool_in_use_connections", "The number of connections currently in use.", float64(p.InUse))
+	e.gauge("pool_idle_connections", "The number of idle connections.", float64(p.Idle))
+	e.counter("pool_wait_total", "The total number of connections waited for.", float64(p.WaitCount))
+	e.counter("pool_wait_seconds_total", "The total time blocked waiting for a new connection.", p.WaitDuration.Seconds())
+	e.counter("pool_max_idle_closed_total", "The total number of connections closed due to SetMaxIdleConns.", float64(p.MaxIdleClosed))
+	e.counter("pool_max_idle_time_closed_total", "The total number of connections closed due to SetConnMaxIdleTime.", float64(p.MaxIdleTimeClosed))
+	e.counter("pool_max_lifetime_closed_total", "The total number of connections closed due to SetConnMaxLifetime.", float64(p.MaxLifetimeClosed))
+
+	e.gauge("stmt_cache_size", "The number of cached prepared statements.", float64(s.Cache.Size))
+	e.counter("stmt_cache_hits_total", "The total number of prepare requests served from the cache.", float64(s.Cache.Hits))
+	e.counter("stmt_cache_misses_total", "The total number of statements prepared by the database.", float64(s.Cache.Misses))
+	e.counter("stmt_cache_evictions_total", "The total number of statements evicted from the cache.", float64(s.Cache.Evictions))
+
+	e.header("stmt_calls_total", "counter", "The total number of prepared statement executions.")
+	for i := range s.Statements {
+		e.sample("stmt_calls_total", label("uid", s.Statements[i].UID), float64(s.Statements[i].Calls))
+	}
+
+	e.header("stmt_errors_total", "counter", "The total number of failed prepared statement executions.")
+	for i := range s.Statements {
+		e.sample("stmt_errors_total", label("uid", s.Statements[i].UID), float64(s.Statements[i].Errors))
+	}
+
+	e.header("stmt_rows_fetched_total", "counter", "The total number of rows fetched by prepared statement.")
+	for i := range s.Statements {
+		e.sample("stmt_rows_fetched_total", label("uid", s.Statements[i].UID), float64(s.Statements[i].RowsFetched))
+	}
+
+	e.header("stmt_duration_seconds", "histogram", "Prepared statement execution latency, including fetching of rows.")
+	for i := range s.Statements {
+		ss := &s.Statements[i]
+		e.histogram("stmt_duration_seconds", label("uid", ss.UID), ss.Histogram, ss.TotalTime, ss.Calls)
+	}
+
+	e.header("tx_total", "counter", "The total number of finished transactions.")
+	e.sample("tx_total", label("result", "commit"), float64(s.Tx.Commits))
+	e.sample("tx_total", label("result", "rollback"), float64(s.Tx.Rollbacks))
+
+	e.header("tx_duration_seconds", "histogram", "Transaction duration.")
+	e.histogram("tx_duration_seconds", "", s.Tx.Histogram, s.Tx.TotalTime, s.Tx.Commits+s.Tx.Rollbacks)
+
+	classes := make([]string, 0, len(s.Errors))
+	for k := range s.Errors {
+		classes = append(classes, k)
+	}
+	sort.Strings(classes)
+
+	e.header("errors_total", "counter", "The total number of failed statements and transactions by SQLSTATE class.")
+	for _, c := range classes {
+		e.sample("errors_total", label("class", c), float64(s.Errors[c]))
+	}
+}
+
+func (e *encoder) gauge(name, help string, v float64) {
+	e.header(name, "gauge", help)
+	e.sample(name, "", v)
+}
+
+func (e *encoder) counter(name, help string, v float64) {
+	e.header(name, "counter", help)
+	e.sample(name, "", v)
+}
+
+func (e *encoder) histogram(name, labels string, hist []uint64, sum time.Duration, count uint64) {
+	var cum uint64
+	for i, b := range dbw.StatsBuckets {
+		if i < len(hist) {
+			cum += hist[i]
+		}
+		e.sample(name+"_bucket", labels+label("le", formatFloat(b.Seconds())), float64(cum))
+	}
+	e.sample(name+"_bucket", labels+label("le", "+Inf"), float64(count))
+	e.sample(name+"_sum", labels, sum.Seconds())
+	e.sample(name+"_count", labels, float64(count))
+}
+
+func (e *encoder) header(name, typ, help string) {
+	e.w.WriteString("# HELP " + e.ns + "_" + name + " " + help + "\n")
+	e.w.WriteString("# TYPE " + e.ns + "_" + name + " " + typ + "\n")
+}
+
+func (e *encoder) sample(name, labels string, v float64) {
+	e.w.WriteString(e.ns + "_" + name)
+	if labels = e.labels + labels; labels != "" {
+		e.w.WriteString("{" + strings.TrimSuffix(labels, ",") + "}")
+	}
+	e.w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+// label returns label pair followed by comma.
+func label(name, value string) string {
+	return name + `="` + labelEscaper.Replace(value) + `",`
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
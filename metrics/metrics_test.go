@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/axkit/dbw"
+)
+
+func TestWriteText(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, dbw.Inherit(nil), WithNamespace("app"), WithConstLabel("db", "main")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		"# TYPE app_pool_open_connections gauge\n",
+		`app_pool_open_connections{db="main"} 0` + "\n",
+		`app_tx_total{db="main",result="commit"} 0` + "\n",
+		`app_tx_duration_seconds_bucket{db="main",le="+Inf"} 0` + "\n",
+		`app_tx_duration_seconds_bucket{db="main",le="0.001"} 0` + "\n",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in output:\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestLabel(t *testing.T) {
+	if got := label("uid", "a\"b\\c\n"); got != `uid="a\"b\\c\n",` {
+		t.Errorf("unexpected label %s", got)
+	}
+}
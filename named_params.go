@@ -0,0 +1,319 @@
+package dbw
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/axkit/errors"
+)
+
+var (
+	ErrInvalidNamedQuery = errors.New("invalid query with named parameters").StatusCode(500)
+	ErrInvalidNamedArg   = errors.New("named parameters shall be passed by struct or map").StatusCode(500)
+	ErrMissingNamedParam = errors.New("named parameter value not found").StatusCode(500)
+)
+
+// namedQuery holds query with named parameters rewritten to positional ones.
+type namedQuery struct {
+	// text holds rewritten query.
+	text string
+
+	// names holds parameter names in order of positional parameters.
+	names []string
+}
+
+// namedStmt returns statement of query qry with named parameters rewritten
+// to positional ones and names of the parameters in order of positions.
+// The statement is cached by original text, so every query is parsed once
+// while the statement stays in the cache.
+func (db *DB) namedStmt(ctx context.Context, qry string) (*Stmt, []string, error) {
+	ph := db.PlaceHolderType()
+	uid := calcHash([]byte(strconv.Itoa(int(ph)) + ":" + qry))
+
+	var nq *namedQuery
+
+	s, ok := db.Stmt(uid)
+	if !ok {
+		var err error
+		if nq, err = rewriteNamed(qry, ph); err != nil {
+			return nil, nil, err
+		}
+		s = db.prepareContext(ctx, uid, nq.text, "", false)
+	}
+
+	s.namedOnce.Do(func() {
+		if nq == nil {
+			// cached statement is rewritten successfully.
+			nq, _ = rewriteNamed(qry, ph)
+		}
+		s.named = nq.names
+	})
+	return s, s.named, nil
+}
+
+// rewriteNamed replaces placeholders :name and @name by positional ones.
+// String literals, quoted identifiers, comments and type casts (::type) are
+// left untouched. With DollarPlusPosition placeholders every name gets one
+// position even if it's used several times.
+func rewriteNamed(qry string, ph ParamPlaceHolderType) (*namedQuery, error) {
+
+	var (
+		sb         strings.Builder
+		nq         namedQuery
+		positional bool
+		pos        = make(map[string]int)
+	)
+
+	sb.Grow(len(qry))
+
+	for i := 0; i < len(qry); {
+		if end, ok := skipNonCode(qry, i); ok {
+			sb.WriteString(qry[i:end])
+			i = end
+			continue
+		}
+
+		c := qry[i]
+		switch {
+		case c == '$' && i+1 < len(qry) && isDigit(qry[i+1]) && (i == 0 || !isIdentChar(qry[i-1])):
+			positional = true
+		case (c == ':' || c == '@') && (i == 0 || !isIdentChar(qry[i-1])):
+			end := i + 1
+			for end < len(qry) && isIdentChar(qry[end]) {
+				end++
+			}
+			if end == i+1 || isDigit(qry[i+1]) {
+				// not a parameter: operator or array slice.
+				break
+			}
+
+			name := qry[i+1 : end]
+			switch ph {
+			case DollarPlusPosition:
+				n, ok := pos[name]
+				if !ok {
+					nq.names = append(nq.names, name)
+					n = len(nq.names)
+					pos[name] = n
+				}
+				sb.WriteString("$" + strconv.Itoa(n))
+			default:
+				nq.names = append(nq.names, name)
+				sb.WriteByte('?')
+			}
+			i = end
+			continue
+		}
+
+		sb.WriteByte(c)
+		i++
+	}
+
+	if positional && len(nq.names) > 0 {
+		return nil, ErrInvalidNamedQuery.Capture().Set("query", qry).Msg("named and positional parameters are mixed")
+	}
+
+	nq.text = sb.String()
+	return &nq, nil
+}
+
+// skipNonCode returns position after string literal, quoted identifier,
+// comment or type cast started at i of qry. Returns false if there is none.
+func skipNonCode(qry string, i int) (int, bool) {
+	c := qry[i]
+	switch {
+	case c == '\'':
+		escaped := i > 0 && (qry[i-1] == 'E' || qry[i-1] == 'e') && (i < 2 || !isIdentChar(qry[i-2]))
+		return skipQuoted(qry, i, '\'', escaped), true
+	case c == '"':
+		return skipQuoted(qry, i, '"', false), true
+	case c == '-' && strings.HasPrefix(qry[i:], "--"):
+		if end := strings.IndexByte(qry[i:], '\n'); end >= 0 {
+			return i + end + 1, true
+		}
+		return len(qry), true
+	case c == '/' && strings.HasPrefix(qry[i:], "/*"):
+		return skipBlockComment(qry, i), true
+	case c == ':' && strings.HasPrefix(qry[i:], "::"):
+		return i + 2, true
+	case c == '$' && (i == 0 || !isIdentChar(qry[i-1])):
+		tag := dollarQuoteTag(qry[i:])
+		if tag == "" {
+			return 0, false
+		}
+		if end := strings.Index(qry[i+len(tag):], tag); end >= 0 {
+			return i + len(tag) + end + len(tag), true
+		}
+		return len(qry), true
+	}
+	return 0, false
+}
+
+// skipQuoted returns position after the quoted literal started at i.
+// Doubled quote is an escaped quote. If escaped is true, backslash escapes
+// the next character as in E'...' strings.
+func skipQuoted(s string, i int, q byte, escaped bool) int {
+	for j := i + 1; j < len(s); j++ {
+		switch {
+		case escaped && s[j] == '\\':
+			j++
+		case s[j] == q && j+1 < len(s) && s[j+1] == q:
+			j++
+		case s[j] == q:
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+// skipBlockComment returns position after possibly nested comment started at i.
+func skipBlockComment(s string, i int) int {
+	depth := 0
+	for j := i; j+1 < len(s); j++ {
+		switch {
+		case s[j] == '/' && s[j+1] == '*':
+			depth++
+			j++
+		case s[j] == '*' && s[j+1] == '/':
+			depth--
+			j++
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return len(s)
+}
+
+// dollarQuoteTag returns opening tag of dollar-quoted string ($$ or $tag$)
+// at the beginning of s or empty string.
+func dollarQuoteTag(s string) string {
+	for j := 1; j < len(s); j++ {
+		switch {
+		case s[j] == '$':
+			return s[:j+1]
+		case isDigit(s[j]) && j == 1, !isIdentChar(s[j]):
+			return ""
+		}
+	}
+	return ""
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+// namedArgs returns values of parameters names taken from arg. Argument arg
+// is a map with string keys or a struct (pointer to struct) which fields are
+// referenced by column names: snake case field name or tag "col" value.
+func namedArgs(names []string, arg interface{}) ([]interface{}, error) {
+
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	v := reflect.ValueOf(arg)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+
+	res := make([]interface{}, len(names))
+
+	switch {
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		for i, name := range names {
+			mv := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			if !mv.IsValid() {
+				return nil, ErrMissingNamedParam.Capture().Set("param", name)
+			}
+			res[i] = mv.Interface()
+		}
+	case v.Kind() == reflect.Struct:
+		fields := structFields(v.Type())
+		for i, name := range names {
+			sf, ok := fields[name]
+			if !ok {
+				return nil, ErrMissingNamedParam.Capture().Set("param", name).Set("type", v.Type().String())
+			}
+			res[i] = fieldValue(v, sf.index)
+		}
+	default:
+		return nil, ErrInvalidNamedArg.Capture().Set("type", fmt.Sprintf("%T", arg))
+	}
+
+	return res, nil
+}
+
+// fieldValue returns value of the field by index path or nil if the field
+// belongs to nested struct referenced by nil pointer.
+func fieldValue(v reflect.Value, index []int) interface{} {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v.Interface()
+}
+
+// namedInstance returns statement instance and positional arguments of
+// query qry with named parameters.
+func (db *DB) namedInstance(ctx context.Context, tx *Tx, qry string, arg interface{}) (*StmtInstance, []interface{}) {
+
+	s, names, err := db.namedStmt(ctx, qry)
+	if err != nil {
+		return &StmtInstance{err: err}, nil
+	}
+
+	args, err := namedArgs(names, arg)
+	if err != nil {
+		return &StmtInstance{err: errors.Catch(err).Set("query", qry)}, nil
+	}
+
+	if text, expanded := db.expandSlices(ctx, s.text, args); text != s.text {
+		s, args = db.PrepareContext(ctx, text), expanded
+	}
+	if tx != nil {
+		return s.InstanceTx(tx), args
+	}
+	return s.Instance(), args
+}
+
+// QueryNamed executes query with named parameters (:name or @name) taking
+// their values from arg, a struct or map.
+//
+//	it := db.QueryNamed(ctx, "SELECT id FROM users WHERE name = :name AND role = :role", &u).Iter()
+func (db *DB) QueryNamed(ctx context.Context, qry string, arg interface{}) *StmtInstance {
+	si, args := db.namedInstance(ctx, nil, qry, arg)
+	return si.QueryContext(ctx, args...)
+}
+
+// QueryNamedTx executes query with named parameters in transaction tx.
+func (db *DB) QueryNamedTx(ctx context.Context, tx *Tx, qry string, arg interface{}) *StmtInstance {
+	si, args := db.namedInstance(ctx, tx, qry, arg)
+	return si.QueryContext(ctx, args...)
+}
+
+// ExecNamed executes statement with named parameters (:name or @name)
+// taking their values from arg, a struct or map.
+func (db *DB) ExecNamed(ctx context.Context, qry string, arg interface{}) (sql.Result, error) {
+	si, args := db.namedInstance(ctx, nil, qry, arg)
+	return si.ExecContext(ctx, args...)
+}
+
+// ExecNamedTx executes statement with named parameters in transaction tx.
+func (db *DB) ExecNamedTx(ctx context.Context, tx *Tx, qry string, arg interface{}) (sql.Result, error) {
+	si, args := db.namedInstance(ctx, tx, qry, arg)
+	return si.ExecContext(ctx, args...)
+}
@@ -0,0 +1,133 @@
+package dbw
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/axkit/errors"
+)
+
+func TestRewriteNamed(t *testing.T) {
+
+	cases := []struct {
+		name  string
+		src   string
+		ph    ParamPlaceHolderType
+		text  string
+		names []string
+	}{
+		{"dollar", "SELECT id FROM users WHERE name = :name AND (role = @role OR owner = :name)", DollarPlusPosition,
+			"SELECT id FROM users WHERE name = $1 AND (role = $2 OR owner = $1)", []string{"name", "role"}},
+		{"question", "UPDATE t SET a = :a WHERE b = :b AND c = :a", QuestionMark,
+			"UPDATE t SET a = ? WHERE b = ? AND c = ?", []string{"a", "b", "a"}},
+		{"cast", "SELECT :ts::timestamptz, data->>'x'::int", DollarPlusPosition,
+			"SELECT $1::timestamptz, data->>'x'::int", []string{"ts"}},
+		{"literals", `SELECT ':x', E'\':y', "col:z", $$ :w $$, $f$ @v $f$ FROM t WHERE a = :a`, DollarPlusPosition,
+			`SELECT ':x', E'\':y', "col:z", $$ :w $$, $f$ @v $f$ FROM t WHERE a = $1`, []string{"a"}},
+		{"comments", "SELECT 1 -- :x\n/* :y /* :z */ */ WHERE a = :a", DollarPlusPosition,
+			"SELECT 1 -- :x\n/* :y /* :z */ */ WHERE a = $1", []string{"a"}},
+		{"operators", "SELECT arr[1:2], @ -5, tags @> :tags FROM t", DollarPlusPosition,
+			"SELECT arr[1:2], @ -5, tags @> $1 FROM t", []string{"tags"}},
+	}
+
+	for _, c := range cases {
+		nq, err := rewriteNamed(c.src, c.ph)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+			continue
+		}
+		if nq.text != c.text {
+			t.Errorf("%s: expected %q, got %q", c.name, c.text, nq.text)
+		}
+		if len(nq.names) != len(c.names) {
+			t.Errorf("%s: expected names %v, got %v", c.name, c.names, nq.names)
+			continue
+		}
+		for i := range c.names {
+			if nq.names[i] != c.names[i] {
+				t.Errorf("%s: expected names %v, got %v", c.name, c.names, nq.names)
+				break
+			}
+		}
+	}
+
+	if _, err := rewriteNamed("SELECT * FROM t WHERE a = :a AND b = $2", DollarPlusPosition); !errors.Is(err, ErrInvalidNamedQuery) {
+		t.Errorf("expected ErrInvalidNamedQuery, got %v", err)
+	}
+}
+
+func TestNamedArgs(t *testing.T) {
+
+	type Address struct {
+		City string
+	}
+
+	type Customer struct {
+		ID       int
+		FullName string `dbw:"col=name"`
+		Address  *Address
+	}
+
+	args, err := namedArgs([]string{"name", "id", "address__city"}, &Customer{ID: 7, FullName: "Bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[0] != "Bob" || args[1] != 7 || args[2] != nil {
+		t.Errorf("unexpected args %v", args)
+	}
+
+	args, err = namedArgs([]string{"b", "a"}, map[string]interface{}{"a": 1, "b": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[0] != "x" || args[1] != 1 {
+		t.Errorf("unexpected args %v", args)
+	}
+
+	if _, err := namedArgs([]string{"email"}, Customer{}); !errors.Is(err, ErrMissingNamedParam) {
+		t.Errorf("expected ErrMissingNamedParam, got %v", err)
+	}
+
+	if _, err := namedArgs([]string{"a"}, nil); !errors.Is(err, ErrInvalidNamedArg) {
+		t.Errorf("expected ErrInvalidNamedArg, got %v", err)
+	}
+}
+
+func TestDB_ExecNamedCached(t *testing.T) {
+
+	var calls []fakeCall
+	db, srv := newFakeDB(t, recordCalls(&calls))
+	db.SetStmtCacheSize(1)
+
+	const qry = "UPDATE t SET a = :a WHERE id = :id"
+
+	for i := 1; i <= 2; i++ {
+		if _, err := db.ExecNamed(context.Background(), qry, map[string]interface{}{"id": i, "a": "x"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := srv.Count("PREPARE "); n != 1 {
+		t.Errorf("expected query rewritten and prepared once, got %d", n)
+	}
+	if len(calls) != 2 || calls[1].Query != "UPDATE t SET a = $1 WHERE id = $2" ||
+		!reflect.DeepEqual(calls[1].Args, []interface{}{"x", int64(2)}) {
+		t.Errorf("unexpected calls %v", calls)
+	}
+
+	// rewritten queries are evicted together with statements.
+	if _, err := db.ExecNamed(context.Background(), "UPDATE t SET b = :b", map[string]interface{}{"b": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if n := db.PreparedStatementCount(); n != 1 {
+		t.Errorf("expected cache limited by 1 statement, got %d", n)
+	}
+
+	if _, err := db.ExecNamed(context.Background(), qry, map[string]interface{}{"id": 3, "a": "y"}); err != nil {
+		t.Fatal(err)
+	}
+	if c := calls[len(calls)-1]; !reflect.DeepEqual(c.Args, []interface{}{"y", int64(3)}) {
+		t.Errorf("unexpected args after eviction %v", c.Args)
+	}
+}
@@ -0,0 +1,319 @@
+package dbw
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/axkit/errors"
+)
+
+var (
+	ErrInvalidQueryFile  = errors.New("invalid sql file").StatusCode(500).Critical()
+	ErrUnknownNamedQuery = errors.New("unknown named query").StatusCode(500)
+	ErrParamCount        = errors.New("unexpected number of query parameters").StatusCode(500)
+	ErrQuerySetUnbound   = errors.New("query set is not prepared in database").StatusCode(500)
+)
+
+// NamedQuery describes SQL statement loaded from .sql file.
+type NamedQuery struct {
+	// Name holds query name. It's used as prepared statement uid.
+	Name string
+
+	// SQL holds statement text without comments above it.
+	SQL string
+
+	// File and Line hold location of the name marker.
+	File string
+	Line int
+
+	// ParamCount holds expected number of parameters ($1, $2, ...).
+	ParamCount int
+
+	// Model holds name of result model declared by "-- model:" comment.
+	Model string
+
+	// Doc holds other comment lines above the statement.
+	Doc string
+}
+
+// QuerySet holds named queries loaded from .sql files.
+//
+// A file can hold many queries, every query starts with name marker
+// optionally followed by model declaration and description:
+//
+//	-- name: GetActiveUsers
+//	-- model: User
+//	-- Returns users logged in after $1.
+//	SELECT id, name FROM users WHERE last_login_at > $1;
+//
+// Usually the files are embedded:
+//
+//	//go:embed sql/*.sql
+//	var sqlFiles embed.FS
+//
+//	qs, err := dbw.LoadQueries(sqlFiles)
+//	...
+//	if err := qs.SetModel("User", &User{}).Prepare(ctx, db, false).Err(); err != nil {
+//		...
+//	}
+//	it := qs.Iter(ctx, "GetActiveUsers", since)
+type QuerySet struct {
+	db      *DB
+	names   []string
+	queries map[string]*NamedQuery
+	models  map[string]interface{}
+}
+
+// LoadQueries parses all files with .sql extension in fsys.
+func LoadQueries(fsys fs.FS) (*QuerySet, error) {
+
+	qs := &QuerySet{queries: make(map[string]*NamedQuery), models: make(map[string]interface{})}
+
+	err := fs.WalkDir(fsys, ".", func(fn string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || path.Ext(fn) != ".sql" {
+			return nil
+		}
+
+		f, err := fsys.Open(fn)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return qs.parse(fn, f)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(qs.names)
+	return qs, nil
+}
+
+// parse reads named queries from the file.
+func (qs *QuerySet) parse(fn string, f fs.File) error {
+
+	var (
+		q       *NamedQuery
+		sqlText []string
+		doc     []string
+		lineNum int
+	)
+
+	flush := func() error {
+		if q == nil {
+			return nil
+		}
+
+		// comments below the statement are not part of it.
+		for n := len(sqlText); n > 0; n-- {
+			if s := strings.TrimSpace(sqlText[n-1]); s != "" && !strings.HasPrefix(s, "--") {
+				sqlText = sqlText[:n]
+				break
+			}
+			sqlText = sqlText[:n-1]
+		}
+
+		q.SQL = strings.TrimRight(strings.TrimSpace(strings.Join(sqlText, "\n")), ";")
+		if q.SQL == "" {
+			return ErrInvalidQueryFile.Capture().Set("file", fn).Set("line", q.Line).Set("name", q.Name).Msg("empty query")
+		}
+		q.ParamCount = paramCount(q.SQL)
+		q.Doc = strings.Join(doc, "\n")
+
+		if prev, ok := qs.queries[q.Name]; ok {
+			return ErrInvalidQueryFile.Capture().
+				Set("file", fn).Set("line", q.Line).Set("name", q.Name).
+				Set("prevFile", prev.File).Set("prevLine", prev.Line).
+				Msg("duplicate query name")
+		}
+
+		qs.queries[q.Name] = q
+		qs.names = append(qs.names, q.Name)
+		return nil
+	}
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		lineNum++
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if key, val, ok := queryMarker(trimmed); ok {
+			switch {
+			case key == "name":
+				if err := flush(); err != nil {
+					return err
+				}
+				if val == "" {
+					return ErrInvalidQueryFile.Capture().Set("file", fn).Set("line", lineNum).Msg("empty query name")
+				}
+				q = &NamedQuery{Name: val, File: fn, Line: lineNum}
+				sqlText, doc = nil, nil
+				continue
+			case key == "model" && q != nil && len(sqlText) == 0:
+				q.Model = val
+				continue
+			}
+		}
+
+		if q == nil {
+			if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+				continue
+			}
+			return ErrInvalidQueryFile.Capture().Set("file", fn).Set("line", lineNum).Msg("query without name marker")
+		}
+
+		if len(sqlText) == 0 && strings.HasPrefix(trimmed, "--") {
+			doc = append(doc, strings.TrimSpace(strings.TrimPrefix(trimmed, "--")))
+			continue
+		}
+
+		if len(sqlText) == 0 && trimmed == "" {
+			continue
+		}
+		sqlText = append(sqlText, line)
+	}
+
+	if err := sc.Err(); err != nil {
+		return ErrInvalidQueryFile.Capture().Set("file", fn).Set("err", err.Error()).Msg("file read failed")
+	}
+
+	return flush()
+}
+
+// paramCount returns the greatest parameter number ($1, $2, ...) of qry.
+// Parameters inside string literals, quoted identifiers and comments are
+// ignored.
+func paramCount(qry string) int {
+	res := 0
+	for i := 0; i < len(qry); {
+		if end, ok := skipNonCode(qry, i); ok {
+			i = end
+			continue
+		}
+
+		if qry[i] == '$' && i+1 < len(qry) && isDigit(qry[i+1]) && (i == 0 || !isIdentChar(qry[i-1])) {
+			end := i + 1
+			for end < len(qry) && isDigit(qry[end]) {
+				end++
+			}
+			if n, _ := strconv.Atoi(qry[i+1 : end]); n > res {
+				res = n
+			}
+			i = end
+			continue
+		}
+		i++
+	}
+	return res
+}
+
+// queryMarker parses comment line like "-- name: GetUsers".
+func queryMarker(line string) (key, val string, ok bool) {
+	if !strings.HasPrefix(line, "--") {
+		return "", "", false
+	}
+
+	line = strings.TrimSpace(line[2:])
+	idx := strings.IndexByte(line, ':')
+	if idx < 0 {
+		return "", "", false
+	}
+
+	key = strings.ToLower(strings.TrimSpace(line[:idx]))
+	if key != "name" && key != "model" {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(line[idx+1:]), true
+}
+
+// Names returns sorted names of the queries.
+func (qs *QuerySet) Names() []string {
+	return append([]string(nil), qs.names...)
+}
+
+// Query returns query by name.
+func (qs *QuerySet) Query(name string) (*NamedQuery, bool) {
+	q, ok := qs.queries[name]
+	return q, ok
+}
+
+// SetModel binds model name used in "-- model:" comments to model type
+// (pointer to struct). Iter returns iterators of the model type for
+// queries declaring the model.
+func (qs *QuerySet) SetModel(name string, model interface{}) *QuerySet {
+	qs.models[name] = model
+	return qs
+}
+
+// Registry returns statement registry holding all queries of the set.
+func (qs *QuerySet) Registry() *StatementRegistry {
+	r := NewStatementRegistry()
+	for _, name := range qs.names {
+		r.Add(name, qs.queries[name].SQL)
+	}
+	return r
+}
+
+// Prepare prepares all queries in db as named statements and binds the set
+// to db. See StatementRegistry.Prepare.
+func (qs *QuerySet) Prepare(ctx context.Context, db *DB, parallel bool) *StatementReport {
+	qs.db = db
+	return qs.Registry().Prepare(ctx, db, parallel)
+}
+
+// stmtInstance returns instance of the named query and its args checking
+// number of args. If slice parameters are expanded, the rewritten query is
+// prepared by generated uid instead of the query name.
+func (qs *QuerySet) stmtInstance(ctx context.Context, name string, args []interface{}) (*NamedQuery, *StmtInstance, []interface{}) {
+	q, ok := qs.queries[name]
+	switch {
+	case !ok:
+		return nil, &StmtInstance{err: ErrUnknownNamedQuery.Capture().Set("name", name)}, nil
+	case qs.db == nil:
+		return q, &StmtInstance{err: ErrQuerySetUnbound.Capture().Set("name", name)}, nil
+	case len(args) != q.ParamCount:
+		return q, &StmtInstance{err: ErrParamCount.Capture().Set("name", name).
+			Set("expected", q.ParamCount).Set("got", len(args))}, nil
+	}
+
+	qry, args := qs.db.expandSlices(ctx, q.SQL, args)
+	if qry != q.SQL {
+		return q, qs.db.PrepareContext(ctx, qry).Instance(), args
+	}
+	return q, qs.db.PrepareContextN(ctx, q.SQL, q.Name).Instance(), args
+}
+
+// Iter executes named query and returns iterator over its rows. If the query
+// declares model bound by SetModel, method Row() of the iterator returns
+// rows as new values of the model type.
+func (qs *QuerySet) Iter(ctx context.Context, name string, args ...interface{}) *Iterator {
+	q, si, args := qs.stmtInstance(ctx, name, args)
+	si = si.QueryContext(ctx, args...)
+
+	if q != nil {
+		if model, ok := qs.models[q.Model]; ok {
+			return si.IterOf(model)
+		}
+	}
+	return si.Iter()
+}
+
+// Exec executes named query not returning rows.
+func (qs *QuerySet) Exec(ctx context.Context, name string, args ...interface{}) (sql.Result, error) {
+	_, si, args := qs.stmtInstance(ctx, name, args)
+	return si.ExecContext(ctx, args...)
+}
@@ -0,0 +1,111 @@
+package dbw
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/axkit/errors"
+)
+
+func TestLoadQueries(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"users.sql": {Data: []byte(`-- Queries of users.
+
+-- name: GetActiveUsers
+-- model: User
+-- Returns users logged in after $1.
+SELECT id, name
+  FROM users
+ WHERE last_login_at > $1 AND status = $2;
+
+-- name: DeleteUser
+DELETE FROM users WHERE id = $1
+`)},
+		"sub/empty.txt": {Data: []byte("not sql")},
+	}
+
+	qs, err := LoadQueries(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := qs.Names(); len(names) != 2 || names[0] != "DeleteUser" || names[1] != "GetActiveUsers" {
+		t.Fatalf("unexpected names %v", names)
+	}
+
+	q, _ := qs.Query("GetActiveUsers")
+	if q.Model != "User" || q.ParamCount != 2 || q.Line != 3 || q.Doc != "Returns users logged in after $1." {
+		t.Errorf("unexpected query %+v", q)
+	}
+
+	if q.SQL != "SELECT id, name\n  FROM users\n WHERE last_login_at > $1 AND status = $2" {
+		t.Errorf("unexpected sql %q", q.SQL)
+	}
+
+	if it := qs.Iter(context.Background(), "Unknown"); !errors.Is(it.Err(), ErrUnknownNamedQuery) {
+		t.Errorf("expected ErrUnknownNamedQuery, got %v", it.Err())
+	}
+}
+
+func TestLoadQueries_Invalid(t *testing.T) {
+
+	tc := map[string]string{
+		"no-name":   "SELECT 1",
+		"empty":     "-- name: A\n\n-- name: B\nSELECT 1",
+		"duplicate": "-- name: A\nSELECT 1\n-- name: A\nSELECT 2",
+	}
+
+	for name, data := range tc {
+		_, err := LoadQueries(fstest.MapFS{"q.sql": {Data: []byte(data)}})
+		if !errors.Is(err, ErrInvalidQueryFile) {
+			t.Errorf("%s: expected ErrInvalidQueryFile, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadQueries_TrailingComments(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"orders.sql": {Data: []byte(`-- name: GetOrder
+SELECT id, note -- note may mention $4
+  FROM orders
+ WHERE id = $1 AND note <> '$3';
+-- TODO: filter by $2 later
+
+-- name: CountOrders
+SELECT count(*) FROM orders
+`)},
+	}
+
+	qs, err := LoadQueries(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q, _ := qs.Query("GetOrder")
+	if exp := "SELECT id, note -- note may mention $4\n  FROM orders\n WHERE id = $1 AND note <> '$3'"; q.SQL != exp {
+		t.Errorf("expected %q, got %q", exp, q.SQL)
+	}
+	if q.ParamCount != 1 {
+		t.Errorf("expected 1 parameter, got %d", q.ParamCount)
+	}
+}
+
+func TestParamCount(t *testing.T) {
+
+	tc := map[string]int{
+		"SELECT 1":                          0,
+		"SELECT $1, $12":                    12,
+		"SELECT $2 /* $5 */":                2,
+		"SELECT '$3', \"$4\", $$ $6 $$, $1": 1,
+		"SELECT a$5 FROM t WHERE b = $2":    2,
+	}
+
+	for qry, exp := range tc {
+		if n := paramCount(qry); n != exp {
+			t.Errorf("%s: expected %d, got %d", qry, exp, n)
+		}
+	}
+}
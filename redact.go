@@ -0,0 +1,425 @@
+package dbw
+
+import (
+	"container/list"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// TagSecret marks model field holding secret (password, token, etc).
+	TagSecret = "secret"
+
+	// TagPII marks model field holding personal data.
+	TagPII = "pii"
+)
+
+// RedactedValue replaces sensitive parameter values in logs and errors.
+var RedactedValue = "*****"
+
+// DefaultRedactor is used by databases without own redactor and by
+// errors not related to a database.
+var DefaultRedactor = NewRedactor()
+
+// DefaultSensitiveColumnPattern matches names of columns holding secrets.
+var DefaultSensitiveColumnPattern = regexp.MustCompile(`(?i)passw|secret|token|api_?key|credential|salt`)
+
+// DefaultSensitiveValuePattern matches JSON Web Tokens.
+var DefaultSensitiveValuePattern = regexp.MustCompile(`eyJ[\w-]+\.[\w-]+\.[\w-]+`)
+
+var (
+	// reParamCmp matches "col = $1", "lower(col) = lower($1)", "col = ANY($1)".
+	reParamCmp = regexp.MustCompile(`(?i)"?([a-z_][a-z0-9_]*)"?\s*\)?\s*(?:=|<>|!=|<=|>=|<|>|\blike\b|\bilike\b)\s*(?:[a-z_][a-z0-9_]*\s*\(\s*)?\$(\d+)`)
+
+	// reParamCmpRev matches "$1 = col", "lower($1) = lower(col)".
+	reParamCmpRev = regexp.MustCompile(`(?i)\$(\d+)\s*\)?\s*(?:=|<>|!=|<=|>=|<|>|\blike\b|\bilike\b)\s*(?:[a-z_][a-z0-9_]*\s*\(\s*)?"?([a-z_][a-z0-9_]*)"?`)
+)
+
+// redactCacheSize limits number of statements which parameter columns are
+// cached by Redactor.
+const redactCacheSize = 1024
+
+// Redactor masks sensitive parameters of SQL statements before they are
+// written to logs or attached to errors. A parameter is sensitive if it's
+// compared with or assigned to a column with sensitive name: column of a
+// model field tagged as `dbw:"secret"` or `dbw:"pii"` by a table of the
+// database, column added by AddColumns or matched by column pattern. Parts
+// of other parameter values matched by value patterns are masked as well.
+//
+// Columns are bound to parameters by the statement text: comparisons with
+// a column or a column wrapped by a function call ("col = $1",
+// "$1 = lower(col)", "col = ANY($1)") and column lists of INSERT for every
+// row of VALUES. Parameters
+// used in other expressions, e.g. "col = $1 || $2" or "col = $1::text", and
+// "?" placeholders are masked only by value patterns.
+type Redactor struct {
+	mux         sync.RWMutex
+	columns     map[string]bool
+	colPatterns []*regexp.Regexp
+	valPatterns []*regexp.Regexp
+
+	// params caches column names of statement parameters by statement text.
+	// Least recently used statements are evicted above redactCacheSize.
+	paramsMux sync.Mutex
+	params    map[string]*list.Element
+	paramsLRU *list.List
+}
+
+// paramColumnsEntry is an element of Redactor.paramsLRU.
+type paramColumnsEntry struct {
+	qry  string
+	cols map[int]string
+}
+
+// NewRedactor returns redactor with DefaultSensitiveColumnPattern and
+// DefaultSensitiveValuePattern.
+func NewRedactor() *Redactor {
+	return &Redactor{
+		columns:     make(map[string]bool),
+		colPatterns: []*regexp.Regexp{DefaultSensitiveColumnPattern},
+		valPatterns: []*regexp.Regexp{DefaultSensitiveValuePattern},
+	}
+}
+
+// AddColumns adds names of sensitive columns.
+func (r *Redactor) AddColumns(names ...string) *Redactor {
+	r.mux.Lock()
+	for i := range names {
+		r.columns[strings.ToLower(names[i])] = true
+	}
+	r.mux.Unlock()
+	return r
+}
+
+// AddColumnPattern adds pattern of sensitive column names.
+func (r *Redactor) AddColumnPattern(re *regexp.Regexp) *Redactor {
+	r.mux.Lock()
+	r.colPatterns = append(r.colPatterns, re)
+	r.mux.Unlock()
+	return r
+}
+
+// AddValuePattern adds pattern of sensitive values like card numbers or
+// e-mails. Matched parts of parameter values are masked.
+func (r *Redactor) AddValuePattern(re *regexp.Regexp) *Redactor {
+	r.mux.Lock()
+	r.valPatterns = append(r.valPatterns, re)
+	r.mux.Unlock()
+	return r
+}
+
+// Redact returns copy of args of statement qry where sensitive values are
+// replaced by RedactedValue. Returns args if nothing is masked.
+func (r *Redactor) Redact(qry string, args []interface{}) []interface{} {
+	return r.redact(qry, args, nil)
+}
+
+// redact masks args of statement qry taking into account columns tagged
+// by tables of a database, if tagged is not nil.
+func (r *Redactor) redact(qry string, args []interface{}, tagged *sync.Map) []interface{} {
+	if len(args) == 0 {
+		return args
+	}
+
+	cols := r.paramColumns(qry)
+
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	var res []interface{}
+	for i := range args {
+		var val interface{} = RedactedValue
+		if !r.isSensitiveColumn(cols[i+1], tagged) {
+			s, ok := r.maskValue(args[i])
+			if !ok {
+				continue
+			}
+			val = s
+		}
+
+		if res == nil {
+			res = make([]interface{}, len(args))
+			copy(res, args)
+		}
+		res[i] = val
+	}
+
+	if res == nil {
+		return args
+	}
+	return res
+}
+
+// isSensitiveColumn returns true if column name is sensitive. Shall be called under read lock.
+func (r *Redactor) isSensitiveColumn(col string, tagged *sync.Map) bool {
+	if col == "" {
+		return false
+	}
+
+	if r.columns[col] {
+		return true
+	}
+
+	if tagged != nil {
+		if _, ok := tagged.Load(col); ok {
+			return true
+		}
+	}
+
+	for _, re := range r.colPatterns {
+		if re.MatchString(col) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskValue returns formatted value with masked sensitive parts, if any.
+// Shall be called under read lock.
+func (r *Redactor) maskValue(arg interface{}) (string, bool) {
+	if len(r.valPatterns) == 0 {
+		return "", false
+	}
+
+	s := fmtArg(arg)
+	masked := false
+	for _, re := range r.valPatterns {
+		if re.MatchString(s) {
+			s = re.ReplaceAllLiteralString(s, RedactedValue)
+			masked = true
+		}
+	}
+	return s, masked
+}
+
+// paramColumns returns lower case column names by parameter numbers of qry.
+func (r *Redactor) paramColumns(qry string) map[int]string {
+	r.paramsMux.Lock()
+	if e, ok := r.params[qry]; ok {
+		r.paramsLRU.MoveToFront(e)
+		r.paramsMux.Unlock()
+		return e.Value.(*paramColumnsEntry).cols
+	}
+	r.paramsMux.Unlock()
+
+	res := parseParamColumns(qry)
+
+	r.paramsMux.Lock()
+	defer r.paramsMux.Unlock()
+	if r.params == nil {
+		r.params = make(map[string]*list.Element)
+		r.paramsLRU = list.New()
+	}
+	if _, ok := r.params[qry]; !ok {
+		r.params[qry] = r.paramsLRU.PushFront(&paramColumnsEntry{qry: qry, cols: res})
+	}
+	for r.paramsLRU.Len() > redactCacheSize {
+		e := r.paramsLRU.Back()
+		r.paramsLRU.Remove(e)
+		delete(r.params, e.Value.(*paramColumnsEntry).qry)
+	}
+	return res
+}
+
+// parseParamColumns returns lower case column names by parameter numbers
+// of qry.
+func parseParamColumns(qry string) map[int]string {
+	res := make(map[int]string)
+	for _, m := range reParamCmp.FindAllStringSubmatch(qry, -1) {
+		if n, err := strconv.Atoi(m[2]); err == nil {
+			res[n] = strings.ToLower(m[1])
+		}
+	}
+
+	for _, m := range reParamCmpRev.FindAllStringSubmatch(qry, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil && res[n] == "" {
+			res[n] = strings.ToLower(m[2])
+		}
+	}
+
+	insertParamColumns(qry, res)
+	return res
+}
+
+// insertParamColumns adds to res columns of parameters placed in VALUES
+// rows of INSERT statement qry.
+func insertParamColumns(qry string, res map[int]string) {
+	i := indexKeyword(qry, 0, "insert")
+	if i < 0 {
+		return
+	}
+	if i = skipSpaces(qry, i); !hasKeyword(qry, i, "into") {
+		return
+	}
+
+	// table name, optionally qualified or quoted
+	i = skipSpaces(qry, i+len("into"))
+	for i < len(qry) && (isIdentChar(qry[i]) || qry[i] == '.' || qry[i] == '"') {
+		if qry[i] == '"' {
+			i = skipQuoted(qry, i, '"', false)
+			continue
+		}
+		i++
+	}
+
+	i = skipSpaces(qry, i)
+	cols, i := parenItems(qry, i)
+	if i < 0 {
+		return
+	}
+	if i = skipSpaces(qry, i); !hasKeyword(qry, i, "values") {
+		return
+	}
+	i += len("values")
+
+	for {
+		var vals []string
+		if vals, i = parenItems(qry, skipSpaces(qry, i)); i < 0 {
+			return
+		}
+
+		for j := 0; j < len(cols) && j < len(vals); j++ {
+			if n, ok := paramNumber(vals[j]); ok {
+				res[n] = strings.ToLower(strings.Trim(cols[j], `"`))
+			}
+		}
+
+		if i = skipSpaces(qry, i); i >= len(qry) || qry[i] != ',' {
+			return
+		}
+		i++
+	}
+}
+
+// parenItems returns trimmed comma separated items of parenthesized list
+// started at i of qry and position after the list. Commas of nested
+// parentheses, literals and comments are skipped. Returns -1 position if
+// there is no list at i.
+func parenItems(qry string, i int) ([]string, int) {
+	if i >= len(qry) || qry[i] != '(' {
+		return nil, -1
+	}
+
+	var res []string
+	depth, start := 0, i+1
+	for j := i; j < len(qry); {
+		if k, ok := skipNonCode(qry, j); ok {
+			j = k
+			continue
+		}
+
+		switch qry[j] {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return append(res, strings.TrimSpace(qry[start:j])), j + 1
+			}
+		case ',':
+			if depth == 1 {
+				res = append(res, strings.TrimSpace(qry[start:j]))
+				start = j + 1
+			}
+		}
+		j++
+	}
+	return nil, -1
+}
+
+// paramNumber returns number of parameter s like "$1" or "$1::text".
+func paramNumber(s string) (int, bool) {
+	if !strings.HasPrefix(s, "$") {
+		return 0, false
+	}
+	if i := strings.Index(s, "::"); i > 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	n, err := strconv.Atoi(s[1:])
+	return n, err == nil
+}
+
+// indexKeyword returns position after keyword kw found in code of qry
+// starting from i. Returns -1 if there is none.
+func indexKeyword(qry string, i int, kw string) int {
+	for i < len(qry) {
+		if j, ok := skipNonCode(qry, i); ok {
+			i = j
+			continue
+		}
+		if hasKeyword(qry, i, kw) {
+			return i + len(kw)
+		}
+		i++
+	}
+	return -1
+}
+
+// hasKeyword returns true if keyword kw is placed at i of qry.
+func hasKeyword(qry string, i int, kw string) bool {
+	end := i + len(kw)
+	return end <= len(qry) && strings.EqualFold(qry[i:end], kw) &&
+		(i == 0 || !isIdentChar(qry[i-1])) && (end == len(qry) || !isIdentChar(qry[end]))
+}
+
+// skipSpaces returns position of the first not white space character of
+// qry starting from i.
+func skipSpaces(qry string, i int) int {
+	for i < len(qry) && (qry[i] == ' ' || qry[i] == '\t' || qry[i] == '\n' || qry[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// SetRedactor sets redactor of parameters written to logs and attached to
+// errors. DefaultRedactor is used if r is nil.
+func (db *DB) SetRedactor(r *Redactor) {
+	db.optMux.Lock()
+	db.redactor = r
+	db.optMux.Unlock()
+}
+
+// Redactor returns redactor of the database.
+func (db *DB) Redactor() *Redactor {
+	if db == nil {
+		return DefaultRedactor
+	}
+
+	db.optMux.RLock()
+	defer db.optMux.RUnlock()
+	if db.redactor == nil {
+		return DefaultRedactor
+	}
+	return db.redactor
+}
+
+// redact returns args of statement qry with masked sensitive values.
+func (db *DB) redact(qry string, args []interface{}) []interface{} {
+	if db == nil {
+		return DefaultRedactor.Redact(qry, args)
+	}
+	return db.Redactor().redact(qry, args, &db.taggedColumns)
+}
+
+// registerTaggedColumns registers columns of model fields tagged as
+// secret or pii as sensitive for statements of the database.
+func (t *Table) registerTaggedColumns() {
+	if t.db == nil {
+		return
+	}
+
+	for _, tags := range t.coltag {
+		_, secret := tags[TagSecret]
+		_, pii := tags[TagPII]
+		if !secret && !pii {
+			continue
+		}
+
+		name := tags[TagCol]
+		if name == "" {
+			name = tags["SnakeName"]
+		}
+		t.db.taggedColumns.Store(strings.ToLower(name), true)
+	}
+}
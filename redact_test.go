@@ -0,0 +1,156 @@
+package dbw
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/axkit/errors"
+	"github.com/lib/pq"
+)
+
+func TestRedactor_Redact(t *testing.T) {
+
+	type User struct {
+		ID       int
+		Login    string
+		Phone    string `dbw:"pii"`
+		Password string
+	}
+	db := &DB{}
+	NewTable(db, "users", &User{})
+
+	r := NewRedactor().AddColumnPattern(regexp.MustCompile(`^card$`)).
+		AddValuePattern(regexp.MustCompile(`\d{4}-\d{4}`))
+	db.SetRedactor(r)
+
+	tc := []struct {
+		name string
+		qry  string
+		args []interface{}
+		exp  []interface{}
+	}{
+		{"update", "UPDATE users SET login=$1, password=$2 WHERE id=$3", []interface{}{"bob", "123", 1}, []interface{}{"bob", RedactedValue, 1}},
+		{"insert", `INSERT INTO users(id, login, "phone") VALUES ($1, $2, $3)`, []interface{}{1, "bob", "555"}, []interface{}{1, "bob", RedactedValue}},
+		{"where", "SELECT * FROM users WHERE card = ANY($1)", []interface{}{"x"}, []interface{}{RedactedValue}},
+		{"value", "SELECT $1", []interface{}{"card 1234-5678"}, []interface{}{"card " + RedactedValue}},
+		{"clean", "SELECT * FROM users WHERE login = $1", []interface{}{"bob"}, []interface{}{"bob"}},
+		{"reversed", "SELECT * FROM users WHERE $1 = phone AND login = $2", []interface{}{"555", "bob"}, []interface{}{RedactedValue, "bob"}},
+		{"function", "SELECT * FROM users WHERE lower(phone) = lower($1) AND $2 = upper(password)", []interface{}{"555", "x"}, []interface{}{RedactedValue, RedactedValue}},
+		{"qualified", "SELECT * FROM users u WHERE u.phone = $1", []interface{}{"555"}, []interface{}{RedactedValue}},
+		{"insert rows", "INSERT INTO users(login, phone) VALUES ($1, $2), ($3, $4)", []interface{}{"bob", "555", "ann", "777"}, []interface{}{"bob", RedactedValue, "ann", RedactedValue}},
+		{"insert literals", "INSERT INTO public.users(note, login, phone) VALUES (concat('a,(', $1), $2, $3::text)", []interface{}{"x", "bob", "555"}, []interface{}{"x", "bob", RedactedValue}},
+	}
+
+	for i := range tc {
+		res := db.redact(tc[i].qry, tc[i].args)
+		if len(res) != len(tc[i].exp) {
+			t.Fatalf("%s: unexpected length %d", tc[i].name, len(res))
+		}
+		for j := range res {
+			if res[j] != tc[i].exp[j] {
+				t.Errorf("%s: expected %v, got %v", tc[i].name, tc[i].exp, res)
+				break
+			}
+		}
+	}
+}
+
+func TestRedactor_TaggedColumnsPerDB(t *testing.T) {
+
+	type Person struct {
+		ID    int
+		Email string `dbw:"pii"`
+	}
+
+	tagged, other := &DB{}, &DB{}
+	NewTable(tagged, "persons", &Person{})
+
+	qry := "SELECT * FROM persons WHERE email = $1"
+	args := []interface{}{"bob@example.com"}
+
+	if res := tagged.redact(qry, args); res[0] != RedactedValue {
+		t.Errorf("expected tagged column redacted, got %v", res)
+	}
+	if res := other.redact(qry, args); res[0] != args[0] {
+		t.Errorf("expected column of another database not redacted, got %v", res)
+	}
+	if res := DefaultRedactor.Redact(qry, args); res[0] != args[0] {
+		t.Errorf("expected tagged column not leaked to default redactor, got %v", res)
+	}
+}
+
+func TestWrapError_TableParams(t *testing.T) {
+
+	type Account struct {
+		ID    int
+		Phone string `dbw:"pii"`
+	}
+
+	failed := &pq.Error{Code: "42703", Message: "column does not exist"}
+	db, _ := newFakeDB(t, func(c *fakeCall) (*fakeRows, error) {
+		return nil, failed
+	})
+	tbl := NewTable(db, "accounts", &Account{})
+
+	_, err := tbl.Count("phone = $1 AND id > $2", "555", 10)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	params, ok := errors.Catch(err).Get("params")
+	if !ok {
+		t.Fatal("expected params attached to error")
+	}
+	if fmt.Sprint(params) != fmt.Sprint([]interface{}{RedactedValue, 10}) {
+		t.Errorf("expected phone redacted, got %v", params)
+	}
+}
+
+func TestRedactor_TableInsert(t *testing.T) {
+
+	type User struct {
+		ID       int
+		Login    string
+		Phone    string `dbw:"pii"`
+		Password string
+	}
+
+	db, _ := newFakeDB(t, nil)
+	tbl := NewTable(db, "users", &User{})
+	if !strings.Contains(tbl.SQL.BasicInsert, "NEXTVAL(") {
+		t.Fatalf("expected insert by sequence, got %s", tbl.SQL.BasicInsert)
+	}
+
+	res := db.redact(tbl.SQL.BasicInsert, []interface{}{"bob", "555", "123"})
+	if res[0] != "bob" || res[1] != RedactedValue || res[2] != RedactedValue {
+		t.Errorf("%s: unexpected params %v", tbl.SQL.BasicInsert, res)
+	}
+
+	qry := tbl.genInsertBatchSQL(2, 3)
+	res = db.redact(qry, []interface{}{"bob", "555", "123", "ann", "777", "456"})
+	exp := []interface{}{"bob", RedactedValue, RedactedValue, "ann", RedactedValue, RedactedValue}
+	for i := range exp {
+		if res[i] != exp[i] {
+			t.Errorf("%s: expected %v, got %v", qry, exp, res)
+			break
+		}
+	}
+}
+
+func TestRedactor_paramColumnsBounded(t *testing.T) {
+
+	r := NewRedactor()
+	for i := 0; i < redactCacheSize+10; i++ {
+		r.Redact(fmt.Sprintf("SELECT * FROM users WHERE id = %d AND password = $1", i), []interface{}{"x"})
+	}
+
+	if n := len(r.params); n != redactCacheSize || r.paramsLRU.Len() != n {
+		t.Errorf("expected %d cached statements, got %d", redactCacheSize, n)
+	}
+
+	if _, ok := r.params["SELECT * FROM users WHERE id = 0 AND password = $1"]; ok {
+		t.Error("expected least recently used statement evicted")
+	}
+}
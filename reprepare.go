@@ -0,0 +1,146 @@
+package dbw
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync/atomic"
+
+	"github.com/lib/pq"
+)
+
+// reprepareSavepoint holds name of savepoint guarding statements in
+// transactions, see SetReprepareInTx.
+const reprepareSavepoint = "dbw_reprepare"
+
+// SetReprepareInTx makes statements executed in transactions run under a
+// savepoint, so they can be re-prepared and retried after schema change
+// as well as statements executed outside transactions. The savepoint is
+// established before the statement and released after its result is
+// consumed, it costs two extra round trips per statement. If disabled
+// (default), the statement failed in a transaction is re-prepared for the
+// next execution, but the error is returned.
+func (db *DB) SetReprepareInTx(b bool) {
+	var v int32
+	if b {
+		v = 1
+	}
+	atomic.StoreInt32(&db.reprepareInTx, v)
+}
+
+// isStalePlanError returns true if err means that prepared statement
+// became invalid: result type is changed by migration or the statement
+// is deallocated on the server.
+func isStalePlanError(err error) bool {
+	switch SQLState(err) {
+	case "26000":
+		return true
+	case "0A000":
+		return strings.Contains(err.Error(), "cached plan must not change result type")
+	}
+	return false
+}
+
+// run executes f by executor of the instance. If prepared statement became
+// invalid, it's re-prepared and f is called once again. Inside transaction
+// f is retried only if SetReprepareInTx is active.
+func (si *StmtInstance) run(ctx context.Context, f func(q queryer, qry string) error) error {
+
+	// evicted statement is closed after the execution. Rows returned
+	// stay readable after closing.
+	si.stmt.acquire()
+	defer si.stmt.release()
+
+	guarded := si.tx != nil && atomic.LoadInt32(&si.stmt.db.reprepareInTx) == 1 && !si.isUnprepared(ctx)
+	if guarded {
+		pos, err := si.tx.guard(ctx)
+		if err != nil {
+			return err
+		}
+		si.guard = pos + 1
+	}
+
+	for retried := false; ; retried = true {
+		q, qry, err := si.executor(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = f(q, qry)
+		if err == nil || retried || qry != "" || !isStalePlanError(err) {
+			return err
+		}
+
+		si.stmt.invalidate(si.parentStmt)
+		si.sqlStmt = nil
+		si.parentStmt = nil
+
+		if si.tx != nil {
+			if !guarded {
+				return err
+			}
+			if e := si.tx.rollbackTo(ctx, reprepareSavepoint); e != nil {
+				return err
+			}
+		}
+	}
+}
+
+// invalidate closes prepared statement ss, if it's still used by the
+// statement. The statement is prepared again on the next execution.
+func (s *Stmt) invalidate(ss *sql.Stmt) {
+	s.prepMux.Lock()
+	defer s.prepMux.Unlock()
+
+	if ss == nil || s.sqlStmt != ss {
+		return
+	}
+
+	_ = s.sqlStmt.Close()
+	s.sqlStmt = nil
+}
+
+// guard establishes savepoint protecting a statement, see SetReprepareInTx.
+// Savepoint of a previous statement left on top of the stack (its result is
+// not consumed or it failed) is replaced in the same round trip. Returns
+// position of the savepoint in the stack.
+func (tx *Tx) guard(ctx context.Context) (int, error) {
+	last := len(tx.savepoints) - 1
+	if last < 0 || tx.savepoints[last] != reprepareSavepoint {
+		if err := tx.savepoint(ctx, reprepareSavepoint); err != nil {
+			return 0, err
+		}
+		return last + 1, nil
+	}
+
+	name := pq.QuoteIdentifier(reprepareSavepoint)
+	if err := tx.execSavepoint(ctx, "RELEASE SAVEPOINT "+name+"; SAVEPOINT ", reprepareSavepoint); err != nil {
+		return 0, err
+	}
+	return last, nil
+}
+
+// unguard releases savepoint established by guard at position pos. The
+// savepoint is kept, if savepoints are established after it: releasing it
+// would destroy them.
+func (tx *Tx) unguard(pos int) {
+	if pos != len(tx.savepoints)-1 || tx.savepoints[pos] != reprepareSavepoint {
+		return
+	}
+	_ = tx.release(tx.ctx, reprepareSavepoint)
+}
+
+// unguard releases savepoint protecting the executed statement, if the
+// statement succeeded. Shall be called when the result is consumed: rows
+// of the connection shall not be open.
+func (si *StmtInstance) unguard() {
+	if si.guard == 0 {
+		return
+	}
+	pos := si.guard - 1
+	si.guard = 0
+
+	if si.err == nil {
+		si.tx.unguard(pos)
+	}
+}
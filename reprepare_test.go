@@ -0,0 +1,134 @@
+package dbw
+
+import (
+	"testing"
+
+	"github.com/axkit/errors"
+	"github.com/lib/pq"
+)
+
+func TestIsStalePlanError(t *testing.T) {
+
+	tc := []struct {
+		name string
+		err  error
+		exp  bool
+	}{
+		{"result-type", &pq.Error{Code: "0A000", Message: "cached plan must not change result type"}, true},
+		{"not-supported", &pq.Error{Code: "0A000", Message: "feature not supported"}, false},
+		{"deallocated", parseError(&pq.Error{Code: "26000", Message: `prepared statement "1" does not exist`}), true},
+		{"unique", &pq.Error{Code: "23505"}, false},
+		{"other", errors.New("failed"), false},
+	}
+
+	for i := range tc {
+		if res := isStalePlanError(tc[i].err); res != tc[i].exp {
+			t.Errorf("%s: expected %v, got %v", tc[i].name, tc[i].exp, res)
+		}
+	}
+}
+
+// stalePlan returns handler failing prepared execution of qry while
+// fails is positive.
+func stalePlan(qry string, fails *int) func(c *fakeCall) (*fakeRows, error) {
+	return func(c *fakeCall) (*fakeRows, error) {
+		if c.Query == qry && c.Prepared && *fails > 0 {
+			*fails--
+			return nil, &pq.Error{Code: "0A000", Message: "cached plan must not change result type"}
+		}
+		return nil, nil
+	}
+}
+
+func TestStmtInstance_runReprepare(t *testing.T) {
+
+	const qry = "UPDATE t SET a = 1"
+
+	fails := 1
+	db, srv := newFakeDB(t, stalePlan(qry, &fails))
+
+	s := db.Prepare(qry)
+	if _, err := s.Instance().Exec(); err != nil {
+		t.Fatalf("expected statement re-prepared and retried, got %v", err)
+	}
+
+	if s != db.Prepare(qry) {
+		t.Error("expected statement re-prepared in place")
+	}
+	if n := srv.Count(qry); n != 2 {
+		t.Errorf("expected 2 executions, got %d", n)
+	}
+	if n := srv.Count("PREPARE " + qry); n != 2 {
+		t.Errorf("expected 2 prepares, got %d", n)
+	}
+	if n := srv.Count("DEALLOCATE " + qry); n != 1 {
+		t.Errorf("expected stale statement closed, got %d", n)
+	}
+}
+
+func TestStmtInstance_runRetriedOnce(t *testing.T) {
+
+	const qry = "UPDATE t SET a = 1"
+
+	fails := 10
+	db, srv := newFakeDB(t, stalePlan(qry, &fails))
+
+	_, err := db.Exec(qry)
+	if SQLState(err) != "0A000" {
+		t.Fatalf("expected stale plan error, got %v", err)
+	}
+	if n := srv.Count(qry); n != 2 {
+		t.Errorf("expected single retry, got %d executions", n)
+	}
+}
+
+func TestStmtInstance_runInTx(t *testing.T) {
+
+	const qry = "UPDATE t SET a = 1"
+
+	t.Run("not-guarded", func(t *testing.T) {
+		fails := 1
+		db, srv := newFakeDB(t, stalePlan(qry, &fails))
+
+		tx := db.Begin()
+		if _, err := db.ExecTx(tx, qry); SQLState(err) != "0A000" {
+			t.Fatalf("expected stale plan error, got %v", err)
+		}
+		tx.Rollback()
+
+		if n := srv.Count("SAVEPOINT "); n != 0 {
+			t.Errorf("expected no savepoints, got %d", n)
+		}
+
+		tx = db.Begin()
+		if _, err := db.ExecTx(tx, qry); err != nil {
+			t.Fatalf("expected statement re-prepared for the next execution, got %v", err)
+		}
+		if err := tx.Commit().Err(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("guarded", func(t *testing.T) {
+		fails := 1
+		db, srv := newFakeDB(t, stalePlan(qry, &fails))
+		db.SetReprepareInTx(true)
+
+		tx := db.Begin()
+		if _, err := db.ExecTx(tx, qry); err != nil {
+			t.Fatalf("expected statement retried under savepoint, got %v", err)
+		}
+		if err := tx.Commit().Err(); err != nil {
+			t.Fatalf("commit failed: %v", err)
+		}
+
+		if n := srv.Count(qry); n != 2 {
+			t.Errorf("expected 2 executions, got %d", n)
+		}
+		for _, cmd := range []string{"SAVEPOINT ", "ROLLBACK TO SAVEPOINT ", "RELEASE SAVEPOINT "} {
+			if n := srv.Count(cmd + `"` + reprepareSavepoint); n != 1 {
+				t.Errorf("expected one %q, got %d", cmd, n)
+			}
+		}
+	})
+}
@@ -0,0 +1,205 @@
+package dbw
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/lib/pq"
+)
+
+// SliceMode defines how slice arguments of statements are passed to the
+// database.
+type SliceMode int
+
+const (
+	// SliceDefault inherits slice mode from context or database.
+	SliceDefault SliceMode = iota
+
+	// SliceArray passes slice as a single array parameter wrapped by
+	// pq.Array. It's suitable for conditions like "id = ANY($1)".
+	SliceArray
+
+	// SliceExpand expands parameter holding slice into list of parameters
+	// with renumbering of the following ones. It's suitable for conditions
+	// like "id IN ($1)", which becomes "id IN ($1, $2, $3)". Empty slice
+	// becomes NULL. Every distinct slice length produces own prepared
+	// statement.
+	SliceExpand
+)
+
+type sliceModeKey struct{}
+
+// WithSliceMode returns context holding slice mode for statements executed
+// with it.
+func WithSliceMode(ctx context.Context, m SliceMode) context.Context {
+	return context.WithValue(ctx, sliceModeKey{}, m)
+}
+
+// SetSliceMode sets default slice mode of the database. Default is
+// SliceArray.
+func (db *DB) SetSliceMode(m SliceMode) {
+	atomic.StoreInt32(&db.sliceMode, int32(m))
+}
+
+// SliceMode returns default slice mode of the database.
+func (db *DB) SliceMode() SliceMode {
+	m := SliceMode(atomic.LoadInt32(&db.sliceMode))
+	if m == SliceDefault {
+		return SliceArray
+	}
+	return m
+}
+
+// sliceModeOf returns slice mode defined by ctx or database.
+func (db *DB) sliceModeOf(ctx context.Context) SliceMode {
+	if ctx != nil {
+		if m, ok := ctx.Value(sliceModeKey{}).(SliceMode); ok && m != SliceDefault {
+			return m
+		}
+	}
+	return db.SliceMode()
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// isSliceArg returns true if arg is a slice not supported by the driver
+// directly. Byte slices and types implementing driver.Valuer are passed as is.
+func isSliceArg(arg interface{}) bool {
+	if arg == nil {
+		return false
+	}
+
+	typ := reflect.TypeOf(arg)
+	return typ.Kind() == reflect.Slice &&
+		typ.Elem().Kind() != reflect.Uint8 &&
+		!typ.Implements(valuerType)
+}
+
+// arrayArgs returns args with slices wrapped by pq.Array. Returns args
+// itself if there are no slices.
+func arrayArgs(args []interface{}) []interface{} {
+	var res []interface{}
+	for i := range args {
+		if !isSliceArg(args[i]) {
+			continue
+		}
+		if res == nil {
+			res = append(make([]interface{}, 0, len(args)), args...)
+		}
+		res[i] = pq.Array(args[i])
+	}
+
+	if res == nil {
+		return args
+	}
+	return res
+}
+
+// expandSlices prepares query qry and its args according to slice mode.
+// In mode SliceExpand parameters holding slices are expanded and the query
+// is rewritten, otherwise the query is returned as is and slices are wrapped
+// by pq.Array on execution.
+func (db *DB) expandSlices(ctx context.Context, qry string, args []interface{}) (string, []interface{}) {
+
+	found := false
+	for i := range args {
+		if isSliceArg(args[i]) {
+			found = true
+			break
+		}
+	}
+
+	if !found || db.sliceModeOf(ctx) != SliceExpand {
+		return qry, args
+	}
+	return expandSliceParams(qry, args, db.PlaceHolderType())
+}
+
+// expandSliceParams replaces parameters holding slices by list of parameters,
+// one per slice element, renumbering the following parameters. Parameters
+// inside string literals and comments are ignored.
+func expandSliceParams(qry string, args []interface{}, ph ParamPlaceHolderType) (string, []interface{}) {
+
+	// pos holds new position of the first element of every argument.
+	pos := make([]int, len(args))
+	res := make([]interface{}, 0, len(args))
+	for i := range args {
+		pos[i] = len(res) + 1
+		if !isSliceArg(args[i]) {
+			res = append(res, args[i])
+			continue
+		}
+		v := reflect.ValueOf(args[i])
+		for j := 0; j < v.Len(); j++ {
+			res = append(res, v.Index(j).Interface())
+		}
+	}
+
+	// list returns placeholders of argument i.
+	list := func(i int) string {
+		n := 1
+		if isSliceArg(args[i]) {
+			if n = reflect.ValueOf(args[i]).Len(); n == 0 {
+				return "NULL"
+			}
+		}
+
+		ps := make([]string, n)
+		for j := range ps {
+			if ph == DollarPlusPosition {
+				ps[j] = "$" + strconv.Itoa(pos[i]+j)
+			} else {
+				ps[j] = "?"
+			}
+		}
+		return strings.Join(ps, ", ")
+	}
+
+	var (
+		sb   strings.Builder
+		qnum int
+	)
+
+	for i := 0; i < len(qry); {
+		if end, ok := skipNonCode(qry, i); ok {
+			sb.WriteString(qry[i:end])
+			i = end
+			continue
+		}
+
+		c := qry[i]
+		switch {
+		case ph == DollarPlusPosition && c == '$' && i+1 < len(qry) && isDigit(qry[i+1]):
+			end := i + 1
+			for end < len(qry) && isDigit(qry[end]) {
+				end++
+			}
+			n, _ := strconv.Atoi(qry[i+1 : end])
+			if n >= 1 && n <= len(args) {
+				sb.WriteString(list(n - 1))
+			} else {
+				sb.WriteString(qry[i:end])
+			}
+			i = end
+			continue
+		case ph == QuestionMark && c == '?':
+			if qnum < len(args) {
+				sb.WriteString(list(qnum))
+			} else {
+				sb.WriteByte(c)
+			}
+			qnum++
+			i++
+			continue
+		}
+
+		sb.WriteByte(c)
+		i++
+	}
+
+	return sb.String(), res
+}
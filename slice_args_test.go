@@ -0,0 +1,163 @@
+package dbw
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestExpandSliceParams(t *testing.T) {
+
+	qry, args := expandSliceParams("SELECT * FROM t WHERE id IN ($2) AND s = $1 AND c IN ($3) AND x = '$2' AND y = $4",
+		[]interface{}{"new", []int{7, 8, 9}, []string{}, true}, DollarPlusPosition)
+
+	exp := "SELECT * FROM t WHERE id IN ($2, $3, $4) AND s = $1 AND c IN (NULL) AND x = '$2' AND y = $5"
+	if qry != exp {
+		t.Errorf("expected %q, got %q", exp, qry)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"new", 7, 8, 9, true}) {
+		t.Errorf("unexpected args %v", args)
+	}
+
+	qry, args = expandSliceParams("SELECT * FROM t WHERE s = ? AND id IN (?)",
+		[]interface{}{"new", []int64{1, 2}}, QuestionMark)
+
+	if exp = "SELECT * FROM t WHERE s = ? AND id IN (?, ?)"; qry != exp {
+		t.Errorf("expected %q, got %q", exp, qry)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"new", int64(1), int64(2)}) {
+		t.Errorf("unexpected args %v", args)
+	}
+}
+
+func TestArrayArgs(t *testing.T) {
+
+	args := []interface{}{1, []byte("raw"), pq.StringArray{"a"}, nil}
+	if res := arrayArgs(args); &res[0] != &args[0] {
+		t.Error("expected args without slices to be returned as is")
+	}
+
+	args = []interface{}{1, []int{1, 2}}
+	res := arrayArgs(args)
+	if _, ok := res[1].(pq.GenericArray); !ok {
+		t.Errorf("expected slice wrapped by pq.Array, got %T", res[1])
+	}
+	if _, ok := args[1].([]int); !ok {
+		t.Error("expected original args to be untouched")
+	}
+}
+
+func TestSliceModeOf(t *testing.T) {
+
+	db := &DB{}
+	if m := db.sliceModeOf(context.Background()); m != SliceArray {
+		t.Errorf("expected SliceArray by default, got %d", m)
+	}
+
+	db.SetSliceMode(SliceExpand)
+	if m := db.sliceModeOf(WithSliceMode(context.Background(), SliceArray)); m != SliceArray {
+		t.Errorf("expected SliceArray from context, got %d", m)
+	}
+
+	qry, args := db.expandSlices(context.Background(), "SELECT $1", []interface{}{[]int{1}})
+	if qry != "SELECT $1" || len(args) != 1 {
+		t.Errorf("unexpected %q %v", qry, args)
+	}
+}
+
+// recordCalls returns handler recording received statements into calls.
+func recordCalls(calls *[]fakeCall) func(c *fakeCall) (*fakeRows, error) {
+	return func(c *fakeCall) (*fakeRows, error) {
+		*calls = append(*calls, *c)
+		return nil, nil
+	}
+}
+
+// findCall returns the first call which query starts with prefix.
+func findCall(calls []fakeCall, prefix string) (fakeCall, bool) {
+	for i := range calls {
+		if strings.HasPrefix(calls[i].Query, prefix) {
+			return calls[i], true
+		}
+	}
+	return fakeCall{}, false
+}
+
+func TestTable_DoSelectIterSlices(t *testing.T) {
+
+	type Item struct {
+		ID   int
+		Name string
+	}
+
+	var calls []fakeCall
+	db, _ := newFakeDB(t, recordCalls(&calls))
+	tbl := NewTable(db, "items", &Item{})
+
+	ctx := WithSliceMode(context.Background(), SliceExpand)
+	it := tbl.DoSelectIter(ctx, "id IN ($1) AND name = $2", "", 0, 0, []int{1, 2}, "x")
+	for it.Next() {
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, ok := findCall(calls, "SELECT")
+	if !ok {
+		t.Fatalf("query not executed: %v", calls)
+	}
+	if !strings.Contains(c.Query, "id IN ($1, $2) AND name = $3") {
+		t.Errorf("expected expanded query, got %q", c.Query)
+	}
+	if !reflect.DeepEqual(c.Args, []interface{}{int64(1), int64(2), "x"}) {
+		t.Errorf("unexpected args %#v", c.Args)
+	}
+
+	calls = nil
+	if err := tbl.ExportCSV(context.Background(), &strings.Builder{}, "id = ANY($1)", []int{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ = findCall(calls, "SELECT"); len(c.Args) != 1 || c.Args[0] != "{1,2}" {
+		t.Errorf("expected slice passed as array, got %#v", c.Args)
+	}
+}
+
+func TestCursor_Slices(t *testing.T) {
+
+	type Item struct {
+		ID   int
+		Name string
+	}
+
+	var calls []fakeCall
+	db, _ := newFakeDB(t, recordCalls(&calls))
+	tbl := NewTable(db, "items", &Item{})
+
+	var row Item
+	ctx := WithSliceMode(context.Background(), SliceExpand)
+	if err := tbl.DoSelectCursor(ctx, nil, 10, "id IN ($1)", "", nil, &row, []int{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, ok := findCall(calls, "DECLARE ")
+	if !ok {
+		t.Fatalf("cursor not declared: %v", calls)
+	}
+	if !strings.HasSuffix(c.Query, "id IN ($1, $2)") || len(c.Args) != 2 {
+		t.Errorf("expected expanded query, got %q %#v", c.Query, c.Args)
+	}
+
+	calls = nil
+	cur := db.DeclareCursor(context.Background(), nil, 10, "SELECT id FROM items WHERE id = ANY($1)", []int{1, 2})
+	for cur.Next() {
+	}
+	if err := cur.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ = findCall(calls, "DECLARE "); len(c.Args) != 1 || c.Args[0] != "{1,2}" {
+		t.Errorf("expected slice passed as array, got %#v", c.Args)
+	}
+}
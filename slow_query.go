@@ -0,0 +1,229 @@
+package dbw
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultExplainInterval holds default minimal interval between EXPLAIN
+	// statements executed for slow queries.
+	DefaultExplainInterval = 10 * time.Second
+
+	// DefaultExplainTimeout holds default timeout of EXPLAIN statement.
+	DefaultExplainTimeout = 5 * time.Second
+)
+
+// SlowQueryOption describes slow query log parameters.
+type SlowQueryOption struct {
+	threshold       time.Duration
+	explain         bool
+	explainInterval time.Duration
+	explainTimeout  time.Duration
+}
+
+// WithExplain makes slow query log run EXPLAIN (FORMAT JSON) for slow
+// statements and write the plan to the log.
+func WithExplain() func(*SlowQueryOption) {
+	return func(o *SlowQueryOption) {
+		o.explain = true
+	}
+}
+
+// WithExplainInterval sets minimal interval between EXPLAIN statements.
+// Slow queries finished within the interval after the last EXPLAIN are
+// logged without plan.
+func WithExplainInterval(d time.Duration) func(*SlowQueryOption) {
+	return func(o *SlowQueryOption) {
+		o.explainInterval = d
+	}
+}
+
+// WithExplainTimeout sets timeout of EXPLAIN statement.
+func WithExplainTimeout(d time.Duration) func(*SlowQueryOption) {
+	return func(o *SlowQueryOption) {
+		o.explainTimeout = d
+	}
+}
+
+// slowQueryLog holds slow query log state of DB.
+type slowQueryLog struct {
+	SlowQueryOption
+
+	// lastExplain holds Unix time in nanoseconds of the last EXPLAIN.
+	lastExplain int64
+
+	// explaining is 1 while EXPLAIN is running.
+	explaining int32
+}
+
+// SetSlowQueryLog activates logging of statements executed longer than
+// threshold. Duration of a statement is RespondedIn + RowsFetchedIn.
+// Zero threshold deactivates the log.
+func (db *DB) SetSlowQueryLog(threshold time.Duration, optFunc ...func(*SlowQueryOption)) {
+	if threshold <= 0 {
+		db.optMux.Lock()
+		db.slowLog = nil
+		db.optMux.Unlock()
+		return
+	}
+
+	sl := &slowQueryLog{SlowQueryOption: SlowQueryOption{
+		threshold:       threshold,
+		explainInterval: DefaultExplainInterval,
+		explainTimeout:  DefaultExplainTimeout,
+	}}
+
+	for i := range optFunc {
+		optFunc[i](&sl.SlowQueryOption)
+	}
+
+	db.optMux.Lock()
+	db.slowLog = sl
+	db.optMux.Unlock()
+}
+
+// SetSlowQueryThreshold overrides slow query threshold of the database
+// for the statement. Negative d excludes the statement from slow query log.
+func (s *Stmt) SetSlowQueryThreshold(d time.Duration) {
+	s.slowThreshold = d
+}
+
+// Duration returns statement execution duration including fetching of rows.
+func (si *StmtInstance) Duration() time.Duration {
+	return si.RespondedIn + si.RowsFetchedIn
+}
+
+// checkSlow writes statement to slow query log if it's executed longer
+// than threshold.
+func (si *StmtInstance) checkSlow() {
+	if si.stmt == nil || si.stmt.db == nil {
+		return
+	}
+
+	db := si.stmt.db
+	db.optMux.RLock()
+	sl := db.slowLog
+	db.optMux.RUnlock()
+	if sl == nil {
+		return
+	}
+
+	threshold := sl.threshold
+	if si.stmt.slowThreshold != 0 {
+		threshold = si.stmt.slowThreshold
+	}
+
+	if threshold < 0 || si.Duration() < threshold {
+		return
+	}
+
+	params := si.QueryParams
+	if params == "" {
+		params = fmtArgs(db.redact(si.stmt.text, si.args)...)
+	}
+
+	e := db.logger.Warn().
+		Str("uid", si.stmt.uid).
+		Str("sql", si.stmt.text).
+		Str("params", params).
+		Dur("respondedIn", si.RespondedIn).
+		Dur("fetchedIn", si.RowsFetchedIn).
+		Int("rows", si.RowsFetched)
+
+	if si.TxID > 0 {
+		e = e.Uint64("tx", si.TxID)
+	}
+
+	if si.err != nil {
+		e = e.Err(si.err)
+	}
+	e.Msg("slow query")
+
+	if sl.explain && isExplainable(si.stmt.text) && sl.acquireExplain() {
+		go sl.runExplain(db, si.stmt.uid, si.stmt.text, snapshotArgs(si.args))
+	}
+}
+
+// acquireExplain returns true if EXPLAIN is allowed now by rate limit.
+func (sl *slowQueryLog) acquireExplain() bool {
+	if !atomic.CompareAndSwapInt32(&sl.explaining, 0, 1) {
+		return false
+	}
+
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&sl.lastExplain)
+	if now-last < int64(sl.explainInterval) {
+		atomic.StoreInt32(&sl.explaining, 0)
+		return false
+	}
+
+	atomic.StoreInt64(&sl.lastExplain, now)
+	return true
+}
+
+// runExplain executes EXPLAIN on a separate connection and writes the plan to the log.
+func (sl *slowQueryLog) runExplain(db *DB, uid, qry string, args []interface{}) {
+	defer atomic.StoreInt32(&sl.explaining, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sl.explainTimeout)
+	defer cancel()
+
+	var plan []byte
+	err := db.sqldb.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+qry, args...).Scan(&plan)
+	if err != nil {
+		db.logger.Warn().Str("uid", uid).Err(err).Msg("slow query explain failed")
+		return
+	}
+
+	if !json.Valid(plan) {
+		db.logger.Warn().Str("uid", uid).Str("plan", string(plan)).Msg("slow query plan")
+		return
+	}
+	db.logger.Warn().Str("uid", uid).RawJSON("plan", plan).Msg("slow query plan")
+}
+
+// isExplainable returns true if statement can be explained.
+func isExplainable(qry string) bool {
+	qry = strings.ToUpper(strings.TrimLeft(qry, " \t\r\n("))
+	for _, p := range []string{"SELECT", "WITH", "INSERT", "UPDATE", "DELETE", "VALUES"} {
+		if strings.HasPrefix(qry, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotArgs returns copy of args with dereferenced pointers, because
+// referenced values can be changed before EXPLAIN is executed.
+func snapshotArgs(args []interface{}) []interface{} {
+	res := make([]interface{}, len(args))
+	for i := range args {
+		v := reflect.ValueOf(args[i])
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			if _, ok := v.Interface().(driver.Valuer); ok {
+				break
+			}
+			v = v.Elem()
+		}
+
+		if !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+			continue
+		}
+
+		if dv, ok := v.Interface().(driver.Valuer); ok {
+			val, err := dv.Value()
+			if err == nil {
+				res[i] = val
+			}
+			continue
+		}
+		res[i] = v.Interface()
+	}
+	return res
+}
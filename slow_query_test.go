@@ -0,0 +1,68 @@
+package dbw
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestStmtInstance_checkSlow(t *testing.T) {
+
+	var buf bytes.Buffer
+	db := &DB{logger: zerolog.New(&buf)}
+	db.SetSlowQueryLog(100 * time.Millisecond)
+
+	s := &Stmt{db: db, uid: "u1", text: "SELECT 1"}
+
+	tc := []struct {
+		name      string
+		threshold time.Duration
+		duration  time.Duration
+		logged    bool
+	}{
+		{"fast", 0, 50 * time.Millisecond, false},
+		{"slow", 0, 150 * time.Millisecond, true},
+		{"stmt-threshold", 200 * time.Millisecond, 150 * time.Millisecond, false},
+		{"stmt-disabled", -1, time.Second, false},
+	}
+
+	for i := range tc {
+		buf.Reset()
+		s.SetSlowQueryThreshold(tc[i].threshold)
+		si := &StmtInstance{stmt: s, RespondedIn: tc[i].duration, args: []interface{}{1}}
+		si.checkSlow()
+
+		if logged := strings.Contains(buf.String(), "slow query"); logged != tc[i].logged {
+			t.Errorf("%s: expected logged %v, got %v", tc[i].name, tc[i].logged, logged)
+		}
+	}
+}
+
+func TestSlowQueryLog_acquireExplain(t *testing.T) {
+
+	sl := &slowQueryLog{SlowQueryOption: SlowQueryOption{explainInterval: time.Hour}}
+
+	if !sl.acquireExplain() {
+		t.Fatal("expected first explain allowed")
+	}
+	sl.explaining = 0
+
+	if sl.acquireExplain() {
+		t.Error("expected explain rejected within interval")
+	}
+}
+
+func TestSnapshotArgs(t *testing.T) {
+
+	i := 10
+	var np *int
+	args := snapshotArgs([]interface{}{&i, np, "a"})
+	i = 20
+
+	if args[0] != 10 || args[1] != nil || args[2] != "a" {
+		t.Errorf("unexpected snapshot %v", args)
+	}
+}
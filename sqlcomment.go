@@ -0,0 +1,149 @@
+package dbw
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ContextExtractor returns value of SQL comment tag taken from ctx. Empty
+// value excludes the tag from comment.
+type ContextExtractor func(ctx context.Context) string
+
+// StaticTag returns extractor of constant value. It's useful for tags
+// like service name or application version. Comment built by static tags
+// only doesn't prevent statements from being prepared.
+func StaticTag(value string) ContextExtractor {
+	return func(ctx context.Context) string {
+		if p, ok := ctx.Value(staticTagKey{}).(*bool); ok {
+			*p = true
+		}
+		return value
+	}
+}
+
+// staticTagKey is a context key of flag set by extractors of StaticTag.
+type staticTagKey struct{}
+
+// isStaticTag returns true if f is created by StaticTag.
+func isStaticTag(f ContextExtractor) bool {
+	static := false
+	f(context.WithValue(context.Background(), staticTagKey{}, &static))
+	return static
+}
+
+// commentTag describes registered SQL comment tag.
+type commentTag struct {
+	key     string
+	extract ContextExtractor
+
+	// static is true if extract is created by StaticTag.
+	static bool
+}
+
+// SetCommentTag registers SQL comment tag. If at least one tag is
+// registered, statements are sent to the database with appended comment
+// in sqlcommenter format built from context of the call:
+//
+//	SELECT ... /*request_id='4f2a',route='%2Fcustomers'*/
+//
+// It makes able to attribute load in pg_stat_activity and logs of the
+// database server. Comment built by StaticTag tags only is constant, it's
+// appended to prepared statements. Statements with comment having values
+// of other tags are executed unprepared, because comment makes the text
+// unique: every execution costs parsing and planning on the server.
+// Prepared statement cache is keyed by the text without comment, a
+// statement is prepared on the first execution without such values.
+// Statements prepared before the call keep the former static comment.
+func (db *DB) SetCommentTag(key string, f ContextExtractor) {
+	db.optMux.Lock()
+	defer db.optMux.Unlock()
+
+	tag := commentTag{key: key, extract: f, static: isStaticTag(f)}
+	tags := make([]commentTag, 0, len(db.commentTags)+1)
+	found := false
+	for _, t := range db.commentTags {
+		if t.key == key {
+			t = tag
+			found = true
+		}
+		tags = append(tags, t)
+	}
+
+	if !found {
+		tags = append(tags, tag)
+		sort.Slice(tags, func(i, j int) bool {
+			return tags[i].key < tags[j].key
+		})
+	}
+	db.commentTags = tags
+
+	var static []commentTag
+	for _, t := range tags {
+		if t.static {
+			static = append(static, t)
+		}
+	}
+	db.staticComment = buildComment(context.Background(), static)
+}
+
+// staticSQLComment returns SQL comment built by StaticTag tags only.
+func (db *DB) staticSQLComment() string {
+	if db == nil {
+		return ""
+	}
+
+	db.optMux.RLock()
+	defer db.optMux.RUnlock()
+	return db.staticComment
+}
+
+// sqlComment returns SQL comment built from ctx by registered tags.
+// Returns empty string if there are no tags or all values are empty.
+func (db *DB) sqlComment(ctx context.Context) string {
+	if db == nil || ctx == nil {
+		return ""
+	}
+
+	db.optMux.RLock()
+	tags := db.commentTags
+	db.optMux.RUnlock()
+	return buildComment(ctx, tags)
+}
+
+// buildComment returns SQL comment built from ctx by tags. Returns empty
+// string if all values are empty.
+func buildComment(ctx context.Context, tags []commentTag) string {
+	var sb strings.Builder
+	for i := range tags {
+		v := tags[i].extract(ctx)
+		if v == "" {
+			continue
+		}
+
+		if sb.Len() == 0 {
+			sb.WriteString("/*")
+		} else {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(url.PathEscape(tags[i].key))
+		sb.WriteString("='")
+		sb.WriteString(url.PathEscape(v))
+		sb.WriteByte('\'')
+	}
+
+	if sb.Len() == 0 {
+		return ""
+	}
+	sb.WriteString("*/")
+	return sb.String()
+}
+
+// appendComment appends comment to SQL statement qry.
+func appendComment(qry, comment string) string {
+	if comment == "" {
+		return qry
+	}
+	return strings.TrimRight(qry, "; \t\r\n") + " " + comment
+}
@@ -0,0 +1,126 @@
+package dbw
+
+import (
+	"context"
+	"testing"
+)
+
+type requestIDKey struct{}
+
+func TestDB_sqlComment(t *testing.T) {
+
+	db := &DB{}
+	if c := db.sqlComment(context.Background()); c != "" {
+		t.Errorf("expected empty comment, got %q", c)
+	}
+
+	db.SetCommentTag("service", StaticTag("billing"))
+	db.SetCommentTag("route", func(ctx context.Context) string { return "/customers/{id}" })
+	db.SetCommentTag("request_id", func(ctx context.Context) string {
+		s, _ := ctx.Value(requestIDKey{}).(string)
+		return s
+	})
+
+	exp := "/*route='%2Fcustomers%2F%7Bid%7D',service='billing'*/"
+	if c := db.sqlComment(context.Background()); c != exp {
+		t.Errorf("expected %q, got %q", exp, c)
+	}
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "a'b*/")
+	exp = "/*request_id='a%27b%2A%2F',route='%2Fcustomers%2F%7Bid%7D',service='billing'*/"
+	if c := db.sqlComment(ctx); c != exp {
+		t.Errorf("expected %q, got %q", exp, c)
+	}
+}
+
+func TestAppendComment(t *testing.T) {
+	if s := appendComment("SELECT 1;\n", "/*a='b'*/"); s != "SELECT 1 /*a='b'*/" {
+		t.Errorf("unexpected %q", s)
+	}
+}
+
+func TestDB_commentedNotPrepared(t *testing.T) {
+
+	const qry = "UPDATE t SET a = 1"
+
+	db, srv := newFakeDB(t, nil)
+	db.SetCommentTag("request_id", func(ctx context.Context) string {
+		s, _ := ctx.Value(requestIDKey{}).(string)
+		return s
+	})
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "r1")
+	if _, err := db.ExecContext(ctx, qry); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Count("PREPARE "); n != 0 {
+		t.Errorf("expected commented statement not prepared, got %d prepares", n)
+	}
+	if n := srv.Count(qry + " /*request_id='r1'*/"); n != 1 {
+		t.Errorf("expected statement sent with comment: %v", srv.Log())
+	}
+
+	if _, err := db.ExecContext(context.Background(), qry); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Count("PREPARE " + qry); n != 1 {
+		t.Errorf("expected cached statement prepared on execution without comment, got %d", n)
+	}
+	if n := db.PreparedStatementCount(); n != 1 {
+		t.Errorf("expected single cached statement, got %d", n)
+	}
+}
+
+func TestDB_staticCommentPrepared(t *testing.T) {
+
+	const (
+		qry     = "UPDATE t SET a = 1"
+		comment = "/*service='billing'*/"
+	)
+
+	var calls []fakeCall
+	db, srv := newFakeDB(t, recordCalls(&calls))
+	db.SetCommentTag("service", StaticTag("billing"))
+
+	for i := 0; i < 2; i++ {
+		if _, err := db.ExecContext(context.Background(), qry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := srv.Count("PREPARE " + qry + " " + comment); n != 1 {
+		t.Errorf("expected statement with static comment prepared once: %v", srv.Log())
+	}
+	if len(calls) != 2 || !calls[0].Prepared || !calls[1].Prepared {
+		t.Errorf("expected prepared executions, got %+v", calls)
+	}
+
+	db.SetCommentTag("request_id", func(ctx context.Context) string {
+		s, _ := ctx.Value(requestIDKey{}).(string)
+		return s
+	})
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "r1")
+	if _, err := db.ExecContext(ctx, qry); err != nil {
+		t.Fatal(err)
+	}
+	if c := calls[len(calls)-1]; c.Prepared || c.Query != qry+" /*request_id='r1',service='billing'*/" {
+		t.Errorf("expected unprepared statement with request comment, got %+v", c)
+	}
+
+	if _, err := db.ExecContext(context.Background(), qry); err != nil {
+		t.Fatal(err)
+	}
+	if c := calls[len(calls)-1]; !c.Prepared || srv.Count("PREPARE ") != 1 {
+		t.Errorf("expected cached statement reused without request id: %v", srv.Log())
+	}
+}
+
+func TestIsStaticTag(t *testing.T) {
+	if !isStaticTag(StaticTag("a")) {
+		t.Error("expected StaticTag recognized")
+	}
+	if isStaticTag(func(context.Context) string { return "a" }) {
+		t.Error("expected custom extractor not static")
+	}
+}
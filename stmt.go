@@ -1,8 +1,10 @@
 package dbw
 
 import (
+	"container/list"
 	"context"
 	"database/sql"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -20,61 +22,116 @@ type Stmt struct {
 	// text holds formatted SQL statement text.
 	text string
 
+	// table holds name of the table prepared the statement.
+	table string
+
+	// sqlStmt holds prepared statement. It's nil until the statement is
+	// prepared, see ExecUnprepared.
 	sqlStmt *sql.Stmt
+	prepMux sync.Mutex
 
 	logger StmtLogger
 	err    error
 
-	// lastInstanceTime holds a time when the statement has been instatiated last time.
+	// slowThreshold overrides slow query threshold of DB, if not zero.
+	slowThreshold time.Duration
+
+	stats stmtStats
+
+	// lastInstanceTime holds a time in Unix nanoseconds when the statement has been instatiated last time.
 	lastInstanceTime int64
+
+	// pinned is true if the statement is never evicted from the cache.
+	pinned bool
+
+	// refs holds number of executions using the statement.
+	refs int32
+
+	// closing is 1 if the statement shall be closed when unused.
+	closing int32
+
+	// lruElem holds element of DB.lru, nil if the statement is not cached
+	// or pinned.
+	lruElem *list.Element
+
+	// named holds names of named parameters in order of positional ones,
+	// if the statement is rewritten from query with named parameters.
+	named     []string
+	namedOnce sync.Once
+}
+
+// newStmt creates statement. If prepare is false, the statement is prepared
+// in the database on the first prepared execution.
+func newStmt(ctx context.Context, db *DB, uid, qry, table string, pinned, prepare bool) *Stmt {
+	s := &Stmt{uid: uid, text: qry, db: db, table: table, pinned: pinned, lastInstanceTime: time.Now().UnixNano()}
+	if prepare {
+		_, s.err = s.prepared(ctx)
+	}
+	return s
 }
 
-func newStmt(ctx context.Context, db *DB, uid string, qry string) *Stmt {
-	s := &Stmt{uid: uid, text: qry, db: db}
-	s.sqlStmt, s.err = db.SQLDB().PrepareContext(ctx, qry)
-	if s.err == nil {
-		return s
+// prepared returns prepared statement, preparing it if needed.
+func (s *Stmt) prepared(ctx context.Context) (*sql.Stmt, error) {
+	s.prepMux.Lock()
+	defer s.prepMux.Unlock()
+
+	if s.sqlStmt != nil {
+		return s.sqlStmt, nil
+	}
+
+	ctx, span := s.db.startSpan(ctx, SpanInfo{Op: SpanPrepare, UID: s.uid, SQL: s.text, Table: s.table})
+	ss, err := s.db.SQLDB().PrepareContext(ctx, appendComment(s.text, s.db.staticSQLComment()))
+	endSpan(span, 0, err)
+	if err == nil {
+		s.sqlStmt = ss
+		return ss, nil
 	}
 
-	if pe, ok := s.err.(*pq.Error); ok {
-		s.err = errors.Catch(s.err).
-			Set("query", qry).
+	if pe, ok := err.(*pq.Error); ok {
+		err = errors.Catch(err).
+			Set("query", s.text).
 			Set("code", pe.Code).
 			Set("name", pe.Code.Name()).
 			Severity(errors.Critical).
 			Msg("prepare sql statement failed")
 	} else {
-		s.err = errors.Catch(s.err).Set("query", qry).Severity(errors.Critical).Msg("prepare sql statement failed")
+		err = errors.Catch(err).Set("query", s.text).Severity(errors.Critical).Msg("prepare sql statement failed")
 	}
 
-	return s
+	return nil, err
 }
 
 func (s *Stmt) Instance() *StmtInstance {
 	if s.err != nil {
 		return newStmtInstance(s, 0, s.err)
 	}
-	si := newStmtInstance(s, s.db.nextStmtNum(), nil)
-	atomic.StoreInt64(&s.lastInstanceTime, time.Now().Unix())
-	return si
+	s.db.touch(s)
+	return newStmtInstance(s, s.db.nextStmtNum(), nil)
 }
 
 func (s *Stmt) InstanceTx(tx *Tx) *StmtInstance {
 	if s.err != nil {
 		return newStmtInstance(s, 0, s.err)
 	}
-	si := newStmtInstanceTx(tx, s, s.db.nextStmtNum(), nil)
-	atomic.StoreInt64(&s.lastInstanceTime, time.Now().Unix())
-	return si
+	s.db.touch(s)
+	return newStmtInstanceTx(tx, s, s.db.nextStmtNum(), nil)
+}
+
+// SetLogger sets logger called around every execution of the statement
+// instead of the database logger.
+func (s *Stmt) SetLogger(l StmtLogger) {
+	s.logger = l
 }
 
 func (s *Stmt) Err() error {
 	return s.err
 }
 
+// Close removes the statement from the cache and closes it. If the statement
+// is used by instances, it's closed after the last instance finishes.
 func (ss *Stmt) Close() error {
-	ss.db.delStmt(ss.uid)
-	return errors.Catch(ss.sqlStmt.Close()).Set("query", ss.text).Msg("sql statement close failed")
+	ss.db.delStmt(ss)
+	return ss.closeWhenUnused()
 }
 
 func (s *Stmt) DB() *DB {
@@ -82,6 +139,12 @@ func (s *Stmt) DB() *DB {
 }
 
 func (s *Stmt) LastInstanceUnixTime() int64 {
+	return atomic.LoadInt64(&s.lastInstanceTime) / int64(time.Second)
+}
+
+// LastInstanceUnixNano returns time in Unix nanoseconds when the statement
+// has been instantiated last time.
+func (s *Stmt) LastInstanceUnixNano() int64 {
 	return atomic.LoadInt64(&s.lastInstanceTime)
 }
 
@@ -92,3 +155,8 @@ func (s *Stmt) UID() string {
 func (s *Stmt) Text() string {
 	return s.text
 }
+
+// Table returns name of the table prepared the statement or empty string.
+func (s *Stmt) Table() string {
+	return s.table
+}
@@ -0,0 +1,188 @@
+package dbw
+
+import (
+	"container/list"
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/axkit/errors"
+)
+
+// StmtCacheStats holds prepared statement cache counters.
+type StmtCacheStats struct {
+	// Size holds number of cached prepared statements.
+	Size int
+
+	// Capacity holds maximal number of cached statements, 0 if unlimited.
+	Capacity int
+
+	// Hits holds number of prepare requests served from the cache.
+	Hits uint64
+
+	// Misses holds number of statements prepared by the database.
+	Misses uint64
+
+	// Evictions holds number of statements evicted from the cache.
+	Evictions uint64
+}
+
+// SetStmtCacheSize limits number of cached prepared statements. If the
+// limit is exceeded, least recently used statement is evicted. Statements
+// prepared with user defined uid (PrepareN, PrepareContextN) are never
+// evicted. Zero n means unlimited cache. Decreased limit takes effect on
+// the next statement added to the cache.
+func (db *DB) SetStmtCacheSize(n int) {
+	atomic.StoreInt64(&db.stmtCacheSize, int64(n))
+}
+
+// StmtCacheStats returns prepared statement cache counters.
+func (db *DB) StmtCacheStats() StmtCacheStats {
+	return StmtCacheStats{
+		Size:      db.PreparedStatementCount(),
+		Capacity:  int(atomic.LoadInt64(&db.stmtCacheSize)),
+		Hits:      atomic.LoadUint64(&db.stmtHits),
+		Misses:    atomic.LoadUint64(&db.stmtMisses),
+		Evictions: atomic.LoadUint64(&db.stmtEvictions),
+	}
+}
+
+// StartStmtJanitor starts goroutine evicting prepared statements not used
+// longer than idle, checking them every interval. The goroutine stops
+// when ctx is done.
+func (db *DB) StartStmtJanitor(ctx context.Context, idle, interval time.Duration) {
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				db.CleanUnusedStatement(idle)
+			}
+		}
+	}()
+}
+
+// cacheStmt adds statement to the cache and returns statements evicted to
+// keep the cache size. Shall be called under write lock.
+func (db *DB) cacheStmt(s *Stmt) []*Stmt {
+	db.ps[s.uid] = s
+	if s.pinned {
+		return nil
+	}
+
+	db.lruMux.Lock()
+	if db.lru == nil {
+		db.lru = list.New()
+	}
+	s.lruElem = db.lru.PushFront(s)
+	db.lruMux.Unlock()
+
+	return db.evictLRU()
+}
+
+// uncacheStmt removes statement from the cache. Shall be called under write lock.
+func (db *DB) uncacheStmt(s *Stmt) {
+	if db.ps[s.uid] == s {
+		delete(db.ps, s.uid)
+	}
+
+	db.lruMux.Lock()
+	if s.lruElem != nil {
+		db.lru.Remove(s.lruElem)
+		s.lruElem = nil
+	}
+	db.lruMux.Unlock()
+}
+
+// touch marks the statement as most recently used.
+func (db *DB) touch(s *Stmt) {
+	atomic.StoreInt64(&s.lastInstanceTime, time.Now().UnixNano())
+	if s.pinned {
+		return
+	}
+
+	db.lruMux.Lock()
+	if s.lruElem != nil {
+		db.lru.MoveToFront(s.lruElem)
+	}
+	db.lruMux.Unlock()
+}
+
+// evictLRU removes least recently used statements from the cache while
+// its size exceeds the limit. Returns removed statements. Shall be called
+// under write lock.
+func (db *DB) evictLRU() []*Stmt {
+	size := int(atomic.LoadInt64(&db.stmtCacheSize))
+	if size <= 0 {
+		return nil
+	}
+
+	var res []*Stmt
+	for len(db.ps) > size {
+		db.lruMux.Lock()
+		var lru *Stmt
+		if db.lru != nil && db.lru.Len() > 0 {
+			lru = db.lru.Back().Value.(*Stmt)
+		}
+		db.lruMux.Unlock()
+
+		if lru == nil {
+			// only pinned statements are left.
+			break
+		}
+		db.uncacheStmt(lru)
+		res = append(res, lru)
+	}
+	return res
+}
+
+// evict closes statements removed from the cache.
+func (db *DB) evict(arr []*Stmt) {
+	for i := range arr {
+		atomic.AddUint64(&db.stmtEvictions, 1)
+		_ = arr[i].closeWhenUnused()
+	}
+}
+
+// acquire marks the statement as used by an execution.
+func (s *Stmt) acquire() {
+	atomic.AddInt32(&s.refs, 1)
+}
+
+// release marks the statement as not used by an execution and closes it,
+// if the statement is evicted.
+func (s *Stmt) release() {
+	if atomic.AddInt32(&s.refs, -1) == 0 && atomic.LoadInt32(&s.closing) == 1 {
+		_ = s.closeSQL()
+	}
+}
+
+// closeWhenUnused closes the statement now, if it's not used, or after the
+// last execution finishes. The statement stays usable: it's prepared again
+// on the next execution and closed after it.
+func (s *Stmt) closeWhenUnused() error {
+	atomic.StoreInt32(&s.closing, 1)
+	return s.closeSQL()
+}
+
+// closeSQL closes underlying statement, if it's not used by an execution.
+// Rows already returned by the statement stay readable.
+func (s *Stmt) closeSQL() error {
+	s.prepMux.Lock()
+	defer s.prepMux.Unlock()
+
+	if s.sqlStmt == nil || atomic.LoadInt32(&s.refs) > 0 {
+		return nil
+	}
+
+	err := s.sqlStmt.Close()
+	s.sqlStmt = nil
+	if err != nil {
+		return errors.Catch(err).Set("query", s.text).Msg("sql statement close failed")
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package dbw
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDB_evictLRU(t *testing.T) {
+
+	db := &DB{ps: make(map[string]*Stmt)}
+	db.SetStmtCacheSize(2)
+
+	// pinned statement is never evicted: b is evicted when c is added,
+	// c is evicted when d is added.
+	var evicted []*Stmt
+	for _, uid := range []string{"a", "b", "c", "d"} {
+		evicted = append(evicted, db.cacheStmt(&Stmt{db: db, uid: uid, pinned: uid == "a"})...)
+	}
+
+	if len(evicted) != 2 || evicted[0].uid != "b" || evicted[1].uid != "c" {
+		t.Fatalf("unexpected evicted statements %v", evicted)
+	}
+
+	db.evict(evicted)
+	if cs := db.StmtCacheStats(); cs.Size != 2 || cs.Evictions != 2 {
+		t.Errorf("unexpected cache stats %+v", cs)
+	}
+	if db.lru.Len() != 1 {
+		t.Errorf("expected 1 statement in LRU list, got %d", db.lru.Len())
+	}
+}
+
+func TestDB_touch(t *testing.T) {
+
+	db := &DB{ps: make(map[string]*Stmt)}
+	db.SetStmtCacheSize(2)
+
+	db.cacheStmt(&Stmt{db: db, uid: "a"})
+	db.cacheStmt(&Stmt{db: db, uid: "b"})
+	db.touch(db.ps["a"])
+
+	evicted := db.cacheStmt(&Stmt{db: db, uid: "c"})
+	if len(evicted) != 1 || evicted[0].uid != "b" {
+		t.Fatalf("expected b evicted, got %v", evicted)
+	}
+}
+
+func TestDB_CleanUnusedStatement(t *testing.T) {
+
+	db := &DB{ps: make(map[string]*Stmt)}
+
+	old := time.Now().Add(-time.Hour).UnixNano()
+	db.cacheStmt(&Stmt{db: db, uid: "a", lastInstanceTime: old, pinned: true})
+	db.cacheStmt(&Stmt{db: db, uid: "b", lastInstanceTime: old})
+	db.cacheStmt(&Stmt{db: db, uid: "c", lastInstanceTime: time.Now().UnixNano()})
+
+	db.CleanUnusedStatement(time.Minute)
+
+	if _, ok := db.ps["b"]; ok || len(db.ps) != 2 {
+		t.Errorf("expected only b evicted, got %v", db.ps)
+	}
+	if db.lru.Len() != 1 {
+		t.Errorf("expected 1 statement in LRU list, got %d", db.lru.Len())
+	}
+}
+
+func TestStmt_closeWhenUnused(t *testing.T) {
+
+	db, srv := newFakeDB(t, nil)
+	ctx := context.Background()
+
+	s := db.PrepareContext(ctx, "UPDATE t SET a = 1")
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// statement in use is closed after the execution.
+	s.acquire()
+	_ = s.closeWhenUnused()
+	if s.sqlStmt == nil {
+		t.Fatal("expected statement in use not closed")
+	}
+	s.release()
+	if s.sqlStmt != nil || srv.Count("DEALLOCATE ") != 1 {
+		t.Fatal("expected statement closed after release")
+	}
+
+	// evicted statement held by caller is prepared again and closed after use.
+	if _, err := s.Instance().ExecContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.sqlStmt != nil || srv.Count("PREPARE ") != 2 || srv.Count("DEALLOCATE ") != 2 {
+		t.Errorf("unexpected statements %v", srv.Log())
+	}
+}
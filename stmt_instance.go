@@ -34,6 +34,12 @@ type StmtInstance struct {
 	// CtxParams holds context/session related parameters.
 	CtxParams string
 
+	// Comment holds SQL comment appended to the statement (see DB.SetCommentTag).
+	Comment string
+
+	// TxID holds transaction ID if statement is executed in a transaction.
+	TxID uint64
+
 	tx *Tx
 
 	// Num holds SQL statement sequential number since application start.
@@ -47,22 +53,112 @@ type StmtInstance struct {
 	result sql.Result
 	row    *sql.Row
 	rows   *sql.Rows
+
+	// finished is true if StmtLogger.After has been called.
+	finished bool
+
+	// args holds statement arguments of the last execution.
+	args []interface{}
+
+	// span holds tracer span of the current execution.
+	span Span
+
+	// mode holds execution mode of the instance.
+	mode ExecMode
+
+	// parentStmt holds prepared statement of Stmt used by sqlStmt.
+	parentStmt *sql.Stmt
+
+	// guard holds 1-based position of savepoint protecting the statement
+	// in the transaction, see SetReprepareInTx.
+	guard int
 }
 
 func newStmtInstance(stmt *Stmt, num uint64, err error) *StmtInstance {
-	return &StmtInstance{stmt: stmt, sqlStmt: stmt.sqlStmt, Num: num, err: err}
+	return &StmtInstance{stmt: stmt, Num: num, err: err}
 }
 
 func newStmtInstanceTx(tx *Tx, stmt *Stmt, num uint64, err error) *StmtInstance {
-	return &StmtInstance{stmt: stmt, sqlStmt: tx.SQLTx().Stmt(stmt.sqlStmt), Num: num, err: err, tx: tx}
+	return &StmtInstance{stmt: stmt, Num: num, err: err, tx: tx, TxID: tx.ID()}
 }
 
 func (si *StmtInstance) ViaTx(tx *Tx) *StmtInstance {
-	si.sqlStmt = tx.SQLTx().Stmt(si.sqlStmt)
+	// statement is bound to the transaction on execution.
+	si.sqlStmt = nil
+	si.parentStmt = nil
 	si.tx = tx
+	si.TxID = tx.ID()
 	return si
 }
 
+// logger returns statement logger of the statement or of the database.
+func (si *StmtInstance) logger() StmtLogger {
+	if si.stmt == nil {
+		return nil
+	}
+	if si.stmt.logger != nil {
+		return si.stmt.logger
+	}
+	if si.stmt.db != nil {
+		return si.stmt.db.StmtLogger()
+	}
+	return nil
+}
+
+// start marks start of statement execution, calls StmtLogger.Before and
+// starts tracer span. Returned context shall be passed to database driver.
+func (si *StmtInstance) start(ctx context.Context, op string, args []interface{}) context.Context {
+	si.At = time.Now()
+	si.finished = false
+	si.args = args
+
+	if si.stmt != nil {
+		si.Comment = si.stmt.db.sqlComment(ctx)
+		ctx, si.span = si.stmt.db.startSpan(ctx, si.spanInfo(op))
+	}
+
+	l := si.logger()
+	if l == nil {
+		return ctx
+	}
+
+	if si.stmt != nil {
+		args = si.stmt.db.redact(si.stmt.text, args)
+	}
+	si.QueryParams = l.ArgsFormat(args...)
+	si.CtxParams = l.ContextFormat(ctx)
+	l.Before(si)
+	return ctx
+}
+
+// finish calls StmtLogger.After once per statement execution.
+func (si *StmtInstance) finish() {
+	if si.row == nil {
+		// single row result is consumed by Scan.
+		si.unguard()
+	}
+
+	if si.finished || si.At.IsZero() {
+		return
+	}
+	si.finished = true
+
+	endSpan(si.span, si.RowsFetched, si.err)
+	si.span = nil
+
+	if l := si.logger(); l != nil {
+		l.After(si)
+	}
+
+	if si.stmt != nil {
+		si.stmt.stats.add(si.Duration(), si.RowsFetched, si.err)
+		if si.stmt.db != nil {
+			si.stmt.db.errStats.add(si.err)
+		}
+	}
+	si.checkSlow()
+}
+
 func (si *StmtInstance) responded(err error) *StmtInstance {
 	si.RespondedIn = time.Since(si.At)
 	si.err = err
@@ -83,6 +179,7 @@ func (si *StmtInstance) Scan(dest ...interface{}) error {
 	case si.row != nil:
 		si.err = si.row.Scan(dest...)
 		nfm = "row not found"
+		si.unguard()
 	default:
 		si.err = errors.New("invalid Scan() call")
 	}
@@ -96,6 +193,7 @@ func (si *StmtInstance) Scan(dest ...interface{}) error {
 		return si.err
 	}
 
+	si.err = parseError(si.err)
 	return si.err
 }
 
@@ -107,7 +205,8 @@ func (si *StmtInstance) Fetch(f func() error, dest ...interface{}) *StmtInstance
 	}
 
 	// TODO: сохранять состояние при закрытии запроса
-	defer si.rows.Close()
+	// rows are closed before StmtLogger.After to report close error.
+	defer si.Close()
 
 	for si.rows.Next() {
 		if si.err = si.rows.Scan(dest...); si.err != nil {
@@ -123,6 +222,11 @@ func (si *StmtInstance) Fetch(f func() error, dest ...interface{}) *StmtInstance
 		(*si).RowsFetched++
 	}
 
+	if si.err = si.rows.Err(); si.err != nil {
+		si.err = parseError(si.err)
+		return si
+	}
+
 	(*si).RowsFetchedIn = time.Now().Sub(si.At.Add(si.RespondedIn))
 	return si
 }
@@ -132,9 +236,10 @@ func (si *StmtInstance) Close() error {
 	if si.rows != nil {
 		err := si.rows.Close()
 		// preserve existing error
-		if si.err == nil {
-			si.err = err
+		if si.err == nil && err != nil {
+			si.err = parseError(err)
 		}
+		si.finish()
 		return si.err
 	}
 
@@ -149,7 +254,8 @@ func (si *StmtInstance) FetchAll(arr interface{}, row interface{}, dest ...inter
 	}
 
 	// TODO: сохранять состояние при закрытии запроса
-	defer si.rows.Close()
+	// rows are closed before StmtLogger.After to report close error.
+	defer si.Close()
 
 	for si.rows.Next() {
 		if si.err = si.rows.Scan(dest...); si.err != nil {
@@ -161,6 +267,11 @@ func (si *StmtInstance) FetchAll(arr interface{}, row interface{}, dest ...inter
 		si.RowsFetched++
 	}
 
+	if si.err = si.rows.Err(); si.err != nil {
+		si.err = parseError(si.err)
+		return si
+	}
+
 	si.RowsFetchedIn = time.Now().Sub(si.At.Add(si.RespondedIn))
 	return si
 }
@@ -212,11 +323,16 @@ func (si *StmtInstance) ExecContext(ctx context.Context, args ...interface{}) (s
 		return nil, si.err
 	}
 
-	si.At = time.Now()
+	args = arrayArgs(args)
+	ctx = si.start(ctx, SpanExec, args)
+	defer si.finish()
 
-	si.result, si.err = si.sqlStmt.ExecContext(ctx, args...)
+	si.err = si.run(ctx, func(q queryer, qry string) (err error) {
+		si.result, err = q.ExecContext(ctx, qry, args...)
+		return err
+	})
+	si.RespondedIn = time.Since(si.At)
 	if si.err == nil {
-		si.RespondedIn = time.Since(si.At)
 		si.saveStat()
 		return si.result, si.err
 	}
@@ -240,14 +356,19 @@ func (si *StmtInstance) QueryRowContext(ctx context.Context, args ...interface{}
 	if si.err != nil {
 		return si
 	}
+	args = arrayArgs(args)
 	si.rows = nil
-	si.At = time.Now()
+	ctx = si.start(ctx, SpanQuery, args)
+	defer si.finish()
+
 	// there is no option to get access to si.row.err immediately, it can be access only in Scan()
-	si.row = si.sqlStmt.QueryRowContext(ctx, args...)
+	err := si.run(ctx, func(q queryer, qry string) error {
+		si.row = q.QueryRowContext(ctx, qry, args...)
+		return si.row.Err()
+	})
+	si.RespondedIn = time.Since(si.At)
 
-	err := si.row.Err()
 	if err == nil {
-		si.RespondedIn = time.Since(si.At)
 		si.saveStat()
 		return si
 	}
@@ -274,12 +395,12 @@ func (si *StmtInstance) QueryRowContext(ctx context.Context, args ...interface{}
 	return si
 }
 
+// QueryRowTx executes query returning a single row in transaction tx.
 func (si *StmtInstance) QueryRowTx(tx *Tx, ctx context.Context, args ...interface{}) *StmtInstance {
-	si.At = time.Now()
-	si.row = tx.SQLTx().StmtContext(ctx, si.sqlStmt).QueryRowContext(ctx, args...)
-	si.RespondedIn = time.Since(si.At)
-	si.saveStat()
-	return si
+	if si.err != nil {
+		return si
+	}
+	return si.ViaTx(tx).QueryRowContext(ctx, args...)
 }
 
 func (si *StmtInstance) QueryContext(ctx context.Context, args ...interface{}) *StmtInstance {
@@ -288,13 +409,18 @@ func (si *StmtInstance) QueryContext(ctx context.Context, args ...interface{}) *
 		return si
 	}
 
-	var err error
-	si.At = time.Now()
-	if si.rows, err = si.sqlStmt.QueryContext(ctx, args...); err != nil {
-		ce := errors.Catch(err).
+	args = arrayArgs(args)
+	si.row = nil
+	ctx = si.start(ctx, SpanQuery, args)
+	err := si.run(ctx, func(q queryer, qry string) (err error) {
+		si.rows, err = q.QueryContext(ctx, qry, args...)
+		return err
+	})
+	if err != nil {
+		ce := errors.Catch(parseError(err)).
 			Set("query", si.stmt.text).
 			Severity(errors.Critical).
-			SetVals("params", args...)
+			SetVals("params", si.stmt.db.redact(si.stmt.text, args)...)
 		fmt.Printf("\n%T\n", err)
 		perr, ok := err.(*pq.Error)
 		if ok {
@@ -305,6 +431,11 @@ func (si *StmtInstance) QueryContext(ctx context.Context, args ...interface{}) *
 
 	si.RespondedIn = time.Since(si.At)
 	si.saveStat()
+
+	// StmtLogger.After is called when rows are fetched or closed.
+	if si.err != nil {
+		si.finish()
+	}
 	return si
 }
 
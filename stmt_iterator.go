@@ -0,0 +1,277 @@
+package dbw
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/axkit/errors"
+)
+
+// Iterator iterates over rows of the result represented by StmtInstance.
+// Iterator closes the result automatically when rows are exhausted or
+// an error occurred. Statistics RowsFetched and RowsFetchedIn of
+// StmtInstance are maintained.
+//
+//	it := db.QueryContext(ctx, qry, 10).Iter()
+//	defer it.Close()
+//	for it.Next() {
+//		var c Customer
+//		if err := it.ScanRow(&c); err != nil {
+//			return err
+//		}
+//	}
+//	return it.Err()
+type Iterator struct {
+	si *StmtInstance
+
+	// typ holds row struct type used by Row().
+	typ reflect.Type
+
+	// addrs returns scan destinations of row, if nil columns are mapped
+	// to struct fields by name.
+	addrs func(row interface{}) []interface{}
+
+	cols   []string
+	plans  map[reflect.Type][]structField
+	closed bool
+}
+
+// Iter returns iterator over rows of the result.
+func (si *StmtInstance) Iter() *Iterator {
+	return &Iterator{si: si}
+}
+
+// IterOf returns iterator over rows of the result. Method Row() of the
+// iterator returns rows as new values of model type (pointer to struct).
+func (si *StmtInstance) IterOf(model interface{}) *Iterator {
+	it := &Iterator{si: si}
+	it.typ, _ = structType(model)
+	return it
+}
+
+// Next prepares the next row for reading by Scan, ScanRow or Row.
+// Returns false if there are no more rows or an error occurred.
+func (it *Iterator) Next() bool {
+	si := it.si
+
+	if it.closed {
+		return false
+	}
+
+	if si.err != nil {
+		it.Close()
+		return false
+	}
+
+	if si.rows == nil {
+		si.err = errors.New("Iter() requires Query() call")
+		it.closed = true
+		return false
+	}
+
+	if !si.rows.Next() {
+		if err := si.rows.Err(); err != nil {
+			si.err = parseError(err)
+		}
+		si.RowsFetchedIn = time.Now().Sub(si.At.Add(si.RespondedIn))
+		it.Close()
+		return false
+	}
+
+	si.RowsFetched++
+	return true
+}
+
+// Scan reads columns of the current row into dest by position.
+func (it *Iterator) Scan(dest ...interface{}) error {
+	if it.si.err != nil {
+		return it.si.err
+	}
+
+	if err := it.si.rows.Scan(dest...); err != nil {
+		return it.fail(parseError(err))
+	}
+	return nil
+}
+
+// ScanRow reads the current row into struct referenced by row. Columns are
+// mapped to struct fields by name like in ScanStruct. Iterators returned
+// by Table map columns the same way as Table.DoSelect.
+func (it *Iterator) ScanRow(row interface{}) error {
+	si := it.si
+	if si.err != nil {
+		return si.err
+	}
+
+	typ, ok := structType(row)
+	if !ok || reflect.ValueOf(row).IsNil() {
+		return it.fail(ErrInvalidScanTarget.Capture().Set("type", fmt.Sprintf("%T", row)))
+	}
+
+	if it.addrs != nil {
+		return it.Scan(it.addrs(row)...)
+	}
+
+	plan, err := it.plan(typ)
+	if err != nil {
+		return it.fail(err)
+	}
+
+	v := reflect.ValueOf(row).Elem()
+	dests := scanDests(v, plan)
+	if err := si.rows.Scan(dests...); err != nil {
+		return it.fail(parseError(err))
+	}
+	scanAssign(v, plan, dests)
+	return nil
+}
+
+// Row returns the current row as a new value of the model type specified
+// by IterOf or by Table. Returns nil if an error occurred.
+func (it *Iterator) Row() interface{} {
+	if it.typ == nil {
+		it.fail(errors.New("Row() requires iterator with model type"))
+		return nil
+	}
+
+	row := reflect.New(it.typ).Interface()
+	if err := it.ScanRow(row); err != nil {
+		return nil
+	}
+	return row
+}
+
+// Err returns error occurred during iteration.
+func (it *Iterator) Err() error {
+	return it.si.err
+}
+
+// Close closes the result. It's safe to call Close several times.
+func (it *Iterator) Close() error {
+	if it.closed {
+		return it.si.err
+	}
+	it.closed = true
+	return it.si.Close()
+}
+
+// StmtInstance returns statement instance of the iterator.
+func (it *Iterator) StmtInstance() *StmtInstance {
+	return it.si
+}
+
+func (it *Iterator) fail(err error) error {
+	it.si.err = err
+	it.Close()
+	return err
+}
+
+// plan returns columns to struct fields mapping, calculated once per struct type.
+func (it *Iterator) plan(typ reflect.Type) ([]structField, error) {
+	if plan, ok := it.plans[typ]; ok {
+		return plan, nil
+	}
+
+	if it.cols == nil {
+		cols, err := it.si.rows.Columns()
+		if err != nil {
+			return nil, parseError(err)
+		}
+		it.cols = cols
+	}
+
+	plan, err := scanPlan(typ, it.cols, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if it.plans == nil {
+		it.plans = make(map[reflect.Type][]structField, 1)
+	}
+	it.plans[typ] = plan
+	return plan, nil
+}
+
+// DoSelectIter returns iterator over table rows complaints with where.
+// RowLock passed among params appends locking clause.
+func (t *Table) DoSelectIter(ctx context.Context, where, order string, offset, limit int, params ...interface{}) *Iterator {
+	return t.doSelectIter(ctx, nil, where, order, offset, limit, params...)
+}
+
+// DoSelectIterTx returns iterator over table rows complaints with where in transaction tx.
+func (t *Table) DoSelectIterTx(ctx context.Context, tx *Tx, where, order string, offset, limit int, params ...interface{}) *Iterator {
+	return t.doSelectIter(ctx, tx, where, order, offset, limit, params...)
+}
+
+func (t *Table) doSelectIter(ctx context.Context, tx *Tx, where, order string, offset, limit int, params ...interface{}) *Iterator {
+
+	var si *StmtInstance
+
+	qry, params, err := t.selectQuery(ctx, tx, where, order, offset, limit, params...)
+	switch {
+	case err != nil:
+		si = &StmtInstance{err: err}
+	default:
+		si = t.instance(ctx, tx, qry).QueryContext(ctx, params...)
+	}
+
+	if si.err != nil {
+		si.err = WrapError(t, si.err)
+	}
+
+	it := si.IterOf(t.model)
+	it.addrs = func(row interface{}) []interface{} {
+		return t.fieldAddrsSelect(row, "", All)
+	}
+	return it
+}
+
+// Stream reads rows in a separate goroutine and sends them to the returned
+// channel as new values of the iterator's model type. Channel capacity is
+// buffer. Reading stops and the result is closed when rows are exhausted,
+// an error occurred or ctx is done. A consumer stopping before the channel
+// is closed must cancel ctx.
+//
+// The error channel receives at most one error and is closed after the
+// rows channel.
+func (it *Iterator) Stream(ctx context.Context, buffer int) (<-chan interface{}, <-chan error) {
+
+	rows := make(chan interface{}, buffer)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(rows)
+		defer it.Close()
+
+		for it.Next() {
+			row := it.Row()
+			if row == nil {
+				break
+			}
+
+			select {
+			case rows <- row:
+			case <-ctx.Done():
+				it.si.err = ctx.Err()
+				it.si.RowsFetchedIn = time.Now().Sub(it.si.At.Add(it.si.RespondedIn))
+				errc <- it.si.err
+				return
+			}
+		}
+
+		if err := it.Err(); err != nil {
+			errc <- err
+		}
+	}()
+
+	return rows, errc
+}
+
+// Stream streams rows of the result as new values of model type (pointer
+// to struct). See Iterator.Stream.
+func (si *StmtInstance) Stream(ctx context.Context, buffer int, model interface{}) (<-chan interface{}, <-chan error) {
+	return si.IterOf(model).Stream(ctx, buffer)
+}
@@ -0,0 +1,327 @@
+package dbw
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/axkit/errors"
+)
+
+type iterRow struct {
+	ID   int
+	Name string
+}
+
+// iterServer returns handler answering every query by n rows with columns
+// id and name. If errAt is positive, row number errAt fails with err.
+func iterServer(n, errAt int, err error) func(c *fakeCall) (*fakeRows, error) {
+	return func(c *fakeCall) (*fakeRows, error) {
+		rows := &fakeRows{cols: []string{"id", "name"}, errAt: errAt, err: err}
+		for i := 1; i <= n; i++ {
+			rows.vals = append(rows.vals, []driver.Value{int64(i), "name"})
+		}
+		return rows, nil
+	}
+}
+
+func TestIterator_Next(t *testing.T) {
+
+	db, srv := newFakeDB(t, iterServer(3, 0, nil))
+
+	it := db.QueryContext(context.Background(), "SELECT id, name FROM items").Iter()
+	defer it.Close()
+
+	var ids []int
+	for it.Next() {
+		var (
+			id   int
+			name string
+		)
+		if err := it.Scan(&id, &name); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[2] != 3 {
+		t.Errorf("unexpected rows %v", ids)
+	}
+	if it.StmtInstance().RowsFetched != 3 {
+		t.Errorf("expected 3 rows fetched, got %d", it.StmtInstance().RowsFetched)
+	}
+	if !srv.allClosed() {
+		t.Error("expected rows closed after exhausting")
+	}
+	if it.Next() {
+		t.Error("expected no rows after exhausting")
+	}
+}
+
+func TestIterator_Row(t *testing.T) {
+
+	db, _ := newFakeDB(t, iterServer(2, 0, nil))
+
+	it := db.QueryContext(context.Background(), "SELECT id, name FROM items").IterOf(&iterRow{})
+	defer it.Close()
+
+	n := 0
+	for it.Next() {
+		row, ok := it.Row().(*iterRow)
+		if !ok {
+			t.Fatalf("expected *iterRow, got %v (%v)", row, it.Err())
+		}
+		n++
+		if row.ID != n || row.Name != "name" {
+			t.Errorf("unexpected row %+v", row)
+		}
+	}
+	if err := it.Err(); err != nil || n != 2 {
+		t.Errorf("expected 2 rows, got %d, %v", n, err)
+	}
+}
+
+func TestIterator_ScanRow(t *testing.T) {
+
+	db, _ := newFakeDB(t, iterServer(1, 0, nil))
+
+	it := db.QueryContext(context.Background(), "SELECT id, name FROM items").Iter()
+	defer it.Close()
+
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+
+	var row iterRow
+	if err := it.ScanRow(&row); err != nil {
+		t.Fatal(err)
+	}
+	if row.ID != 1 || row.Name != "name" {
+		t.Errorf("unexpected row %+v", row)
+	}
+
+	if err := it.ScanRow(row); err == nil {
+		t.Error("expected error scanning into non-pointer")
+	}
+}
+
+func TestIterator_ScanRowNil(t *testing.T) {
+
+	db, _ := newFakeDB(t, iterServer(1, 0, nil))
+
+	for _, row := range []interface{}{nil, (*iterRow)(nil)} {
+		it := db.QueryContext(context.Background(), "SELECT id, name FROM items").Iter()
+		if !it.Next() {
+			t.Fatal(it.Err())
+		}
+		if err := it.ScanRow(row); !errors.Is(err, ErrInvalidScanTarget) {
+			t.Errorf("%T: expected ErrInvalidScanTarget, got %v", row, err)
+		}
+		it.Close()
+	}
+}
+
+func TestIterator_RowWithoutModel(t *testing.T) {
+
+	db, srv := newFakeDB(t, iterServer(1, 0, nil))
+
+	it := db.QueryContext(context.Background(), "SELECT id, name FROM items").Iter()
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	if row := it.Row(); row != nil {
+		t.Errorf("expected nil row, got %v", row)
+	}
+	if it.Err() == nil {
+		t.Error("expected error")
+	}
+	if !srv.allClosed() {
+		t.Error("expected rows closed on error")
+	}
+}
+
+func TestIterator_Close(t *testing.T) {
+
+	db, srv := newFakeDB(t, iterServer(10, 0, nil))
+	l := &recStmtLogger{}
+	db.SetStmtLogger(l)
+
+	it := db.QueryContext(context.Background(), "SELECT id, name FROM items").Iter()
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+
+	if srv.allClosed() {
+		t.Fatal("expected rows open while iterating")
+	}
+
+	if err := it.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !srv.allClosed() {
+		t.Error("expected rows released by early Close")
+	}
+	if err := it.Close(); err != nil {
+		t.Errorf("expected repeated Close succeeded, got %v", err)
+	}
+	if it.Next() {
+		t.Error("expected no rows after Close")
+	}
+	if len(l.after) != 1 {
+		t.Errorf("expected statement logged once, got %d", len(l.after))
+	}
+}
+
+func TestIterator_ErrorMidIteration(t *testing.T) {
+
+	failed := errors.New("connection reset")
+	db, srv := newFakeDB(t, iterServer(3, 2, failed))
+
+	it := db.QueryContext(context.Background(), "SELECT id, name FROM items").Iter()
+	defer it.Close()
+
+	n := 0
+	for it.Next() {
+		n++
+	}
+
+	if n != 1 {
+		t.Errorf("expected 1 row before error, got %d", n)
+	}
+	if !errors.Is(it.Err(), failed) {
+		t.Errorf("expected row error, got %v", it.Err())
+	}
+	if !srv.allClosed() {
+		t.Error("expected rows closed on error")
+	}
+	if err := it.Close(); !errors.Is(err, failed) {
+		t.Errorf("expected Close to return row error, got %v", err)
+	}
+}
+
+func TestIterator_QueryFailed(t *testing.T) {
+
+	failed := errors.New("syntax error")
+	db, _ := newFakeDB(t, func(c *fakeCall) (*fakeRows, error) {
+		return nil, failed
+	})
+
+	it := db.QueryContext(context.Background(), "SELECT").Iter()
+	if it.Next() {
+		t.Error("expected no rows")
+	}
+	if !errors.Is(it.Err(), failed) {
+		t.Errorf("expected query error, got %v", it.Err())
+	}
+
+	it = (&StmtInstance{}).Iter()
+	if it.Next() || it.Err() == nil {
+		t.Error("expected error of iterator without Query")
+	}
+}
+
+func TestTable_DoSelectIter(t *testing.T) {
+
+	db, _ := newFakeDB(t, iterServer(2, 0, nil))
+	tbl := NewTable(db, "items", &iterRow{})
+
+	it := tbl.DoSelectIter(context.Background(), "id > $1", "id", 0, 0, 0)
+	defer it.Close()
+
+	n := 0
+	for it.Next() {
+		row, ok := it.Row().(*iterRow)
+		if !ok {
+			t.Fatal(it.Err())
+		}
+		n++
+		if row.ID != n {
+			t.Errorf("unexpected row %+v", row)
+		}
+	}
+	if err := it.Err(); err != nil || n != 2 {
+		t.Errorf("expected 2 rows, got %d, %v", n, err)
+	}
+}
+
+func TestIterator_Stream(t *testing.T) {
+
+	db, srv := newFakeDB(t, iterServer(3, 0, nil))
+
+	rows, errc := db.QueryContext(context.Background(), "SELECT id, name FROM items").Stream(context.Background(), 1, &iterRow{})
+
+	n := 0
+	for row := range rows {
+		n++
+		if r, ok := row.(*iterRow); !ok || r.ID != n {
+			t.Errorf("unexpected row %#v", row)
+		}
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 rows, got %d", n)
+	}
+	if !srv.allClosed() {
+		t.Error("expected rows closed")
+	}
+}
+
+func TestIterator_StreamCanceled(t *testing.T) {
+
+	db, srv := newFakeDB(t, iterServer(100, 0, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rows, errc := db.QueryContext(ctx, "SELECT id, name FROM items").Stream(ctx, 0, &iterRow{})
+
+	<-rows
+	cancel()
+
+	n := 1
+	for range rows {
+		n++
+	}
+
+	err := <-errc
+	if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("expected cancellation error, got %v", err)
+	}
+	if n >= 100 {
+		t.Errorf("expected streaming stopped, got %d rows", n)
+	}
+	if !srv.allClosed() {
+		t.Error("expected rows closed after cancellation")
+	}
+}
+
+func TestIterator_StreamRowError(t *testing.T) {
+
+	failed := errors.New("connection reset")
+	db, srv := newFakeDB(t, iterServer(3, 3, failed))
+
+	rows, errc := db.QueryContext(context.Background(), "SELECT id, name FROM items").Stream(context.Background(), 0, &iterRow{})
+
+	n := 0
+	for range rows {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("expected 2 rows before error, got %d", n)
+	}
+	if err := <-errc; !errors.Is(err, failed) {
+		t.Errorf("expected row error, got %v", err)
+	}
+	if _, ok := <-errc; ok {
+		t.Error("expected error channel closed")
+	}
+	if !srv.allClosed() {
+		t.Error("expected rows closed")
+	}
+}
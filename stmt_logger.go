@@ -2,8 +2,15 @@ package dbw
 
 import (
 	"context"
+
+	"github.com/rs/zerolog"
 )
 
+// StmtLogger is called around every statement execution. Before is called
+// before sending statement to database, After is called when execution
+// finished: after Exec, QueryRow or after all rows of Query are fetched
+// or closed. StmtInstance passed holds QueryParams and CtxParams formatted
+// by ArgsFormat and ContextFormat, transaction ID and timings.
 type StmtLogger interface {
 	// New(s Statementer, ctx context.Context, qparams string) *StmtInstance
 	// NewTx(tx *Tx, s Statementer, ctx context.Context, qparams string) *StmtInstance
@@ -13,11 +20,21 @@ type StmtLogger interface {
 	ArgsFormat(args ...interface{}) string
 }
 
+// DefaultStmtLogger writes executed statements to zerolog.Logger. Successful
+// statements are written with level Debug, failed ones with level Error.
 type DefaultStmtLogger struct {
+	logger zerolog.Logger
 }
 
+// NewDefaultStmtLogger returns DefaultStmtLogger writing nowhere.
+// Use NewZerologStmtLogger to write statements to a logger.
 func NewDefaultStmtLogger() *DefaultStmtLogger {
-	return &DefaultStmtLogger{}
+	return &DefaultStmtLogger{logger: zerolog.Nop()}
+}
+
+// NewZerologStmtLogger returns DefaultStmtLogger writing to l.
+func NewZerologStmtLogger(l *zerolog.Logger) *DefaultStmtLogger {
+	return &DefaultStmtLogger{logger: l.With().Str("layer", "sql").Logger()}
 }
 
 func (dsl *DefaultStmtLogger) Before(sli *StmtInstance) {
@@ -26,16 +43,52 @@ func (dsl *DefaultStmtLogger) Before(sli *StmtInstance) {
 
 func (dsl *DefaultStmtLogger) After(sli *StmtInstance) {
 
+	var e *zerolog.Event
+	if err := sli.Err(); err != nil {
+		e = dsl.logger.Error().Err(err)
+	} else {
+		e = dsl.logger.Debug()
+	}
+
+	if !e.Enabled() {
+		return
+	}
+
+	e = e.Uint64("num", sli.Num).Dur("respondedIn", sli.RespondedIn)
+
+	if sli.stmt != nil {
+		e = e.Str("uid", sli.stmt.uid).Str("sql", sli.stmt.text)
+	}
+
+	if sli.TxID > 0 {
+		e = e.Uint64("tx", sli.TxID)
+	}
+
+	if sli.rows != nil {
+		e = e.Int("rows", sli.RowsFetched).Dur("fetchedIn", sli.RowsFetchedIn)
+	}
+
+	if sli.QueryParams != "" {
+		e = e.Str("params", sli.QueryParams)
+	}
+
+	if sli.Comment != "" {
+		e = e.Str("comment", sli.Comment)
+	}
+
+	if sli.CtxParams != "" {
+		e = e.Str("ctx", sli.CtxParams)
+	}
+
+	e.Msg("sql")
 }
 
 func (dsl *DefaultStmtLogger) ContextFormat(ctx context.Context) string {
-
-	return ""
+	return fmtContext(ctx)
 }
 
 func (dsl *DefaultStmtLogger) ArgsFormat(args ...interface{}) string {
-
-	return ""
+	return fmtArgs(args...)
 }
 
 /*
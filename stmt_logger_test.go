@@ -0,0 +1,99 @@
+package dbw
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/axkit/errors"
+)
+
+// recStmtLogger records statements passed to StmtLogger.
+type recStmtLogger struct {
+	before int
+	after  []error
+
+	// onAfter is called by After, if not nil.
+	onAfter func(*StmtInstance)
+}
+
+func (l *recStmtLogger) Before(*StmtInstance) { l.before++ }
+
+func (l *recStmtLogger) After(si *StmtInstance) {
+	l.after = append(l.after, si.Err())
+	if l.onAfter != nil {
+		l.onAfter(si)
+	}
+}
+
+func (l *recStmtLogger) ContextFormat(context.Context) string { return "" }
+
+func (l *recStmtLogger) ArgsFormat(args ...interface{}) string { return fmtArgs(args...) }
+
+func TestStmtLogger_RowsCloseError(t *testing.T) {
+
+	closeErr := errors.New("close failed")
+	db, _ := newFakeDB(t, func(c *fakeCall) (*fakeRows, error) {
+		return &fakeRows{cols: []string{"id"}, vals: [][]driver.Value{{int64(1)}, {int64(2)}}, closeErr: closeErr}, nil
+	})
+
+	l := &recStmtLogger{}
+	db.SetStmtLogger(l)
+
+	it := db.QueryContext(context.Background(), "SELECT id FROM t").Iter()
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	it.Close()
+
+	if l.before != 1 || len(l.after) != 1 {
+		t.Fatalf("expected one Before and After call, got %d and %d", l.before, len(l.after))
+	}
+	if l.after[0] == nil {
+		t.Error("expected rows close error passed to After")
+	}
+}
+
+func TestStmtLogger_AfterRowsClosed(t *testing.T) {
+
+	db, srv := newFakeDB(t, func(c *fakeCall) (*fakeRows, error) {
+		return &fakeRows{cols: []string{"id"}, vals: [][]driver.Value{{int64(1)}, {int64(2)}}}, nil
+	})
+
+	failed := errors.New("stop")
+	closed := false
+	db.SetStmtLogger(&recStmtLogger{onAfter: func(*StmtInstance) { closed = srv.allClosed() }})
+
+	var id int
+	err := db.QueryContext(context.Background(), "SELECT id FROM t").Fetch(func() error { return failed }, &id).Err()
+	if err != failed {
+		t.Errorf("expected callback error, got %v", err)
+	}
+	if !closed {
+		t.Error("expected rows closed before StmtLogger.After")
+	}
+}
+
+func TestStmtLogger_QueryRowTx(t *testing.T) {
+
+	db, _ := newFakeDB(t, func(c *fakeCall) (*fakeRows, error) {
+		return &fakeRows{cols: []string{"n"}, vals: [][]driver.Value{{int64(7)}}}, nil
+	})
+
+	l := &recStmtLogger{}
+	db.SetStmtLogger(l)
+
+	tx := db.Begin()
+	var n int
+	if err := db.Prepare("SELECT $1::int").Instance().QueryRowTx(tx, context.Background(), []int{7}).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	tx.Commit()
+
+	if n != 7 {
+		t.Errorf("expected 7, got %d", n)
+	}
+	if l.before != 1 || len(l.after) != 1 {
+		t.Errorf("expected one Before and After call, got %d and %d", l.before, len(l.after))
+	}
+}
@@ -0,0 +1,208 @@
+package dbw
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/axkit/errors"
+	"github.com/lib/pq"
+)
+
+// valueConverter converts value returned by database driver to natural Go value.
+type valueConverter func(src interface{}) (interface{}, error)
+
+// columnConverter returns converter for PostgreSQL type name as it returned
+// by sql.ColumnType.DatabaseTypeName().
+func columnConverter(dbType string) valueConverter {
+
+	dbType = strings.ToUpper(dbType)
+
+	if strings.HasPrefix(dbType, "_") {
+		return arrayConverter(dbType[1:])
+	}
+
+	switch dbType {
+	case "NUMERIC", "DECIMAL", "MONEY":
+		return convertString
+	case "JSON", "JSONB":
+		return convertJSON
+	case "BYTEA":
+		return convertBytes
+	}
+
+	// text representation of other types is returned as string.
+	return convertString
+}
+
+func arrayConverter(elemType string) valueConverter {
+	switch elemType {
+	case "INT2", "INT4", "INT8":
+		return func(src interface{}) (interface{}, error) {
+			var a pq.Int64Array
+			err := a.Scan(src)
+			return []int64(a), err
+		}
+	case "FLOAT4", "FLOAT8":
+		return func(src interface{}) (interface{}, error) {
+			var a pq.Float64Array
+			err := a.Scan(src)
+			return []float64(a), err
+		}
+	case "BOOL":
+		return func(src interface{}) (interface{}, error) {
+			var a pq.BoolArray
+			err := a.Scan(src)
+			return []bool(a), err
+		}
+	case "BYTEA":
+		return func(src interface{}) (interface{}, error) {
+			var a pq.ByteaArray
+			err := a.Scan(src)
+			return [][]byte(a), err
+		}
+	}
+
+	return func(src interface{}) (interface{}, error) {
+		var a pq.StringArray
+		err := a.Scan(src)
+		return []string(a), err
+	}
+}
+
+func convertString(src interface{}) (interface{}, error) {
+	if b, ok := src.([]byte); ok {
+		return string(b), nil
+	}
+	return src, nil
+}
+
+func convertJSON(src interface{}) (interface{}, error) {
+	switch v := src.(type) {
+	case []byte:
+		return json.RawMessage(append([]byte(nil), v...)), nil
+	case string:
+		return json.RawMessage(v), nil
+	}
+	return src, nil
+}
+
+func convertBytes(src interface{}) (interface{}, error) {
+	if b, ok := src.([]byte); ok {
+		return append([]byte(nil), b...), nil
+	}
+	return src, nil
+}
+
+// mapScanner scans rows into maps.
+type mapScanner struct {
+	names []string
+	convs []valueConverter
+	vals  []interface{}
+	dests []interface{}
+}
+
+func newMapScanner(rows *sql.Rows) (*mapScanner, error) {
+	cts, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	ms := &mapScanner{
+		names: make([]string, len(cts)),
+		convs: make([]valueConverter, len(cts)),
+		vals:  make([]interface{}, len(cts)),
+		dests: make([]interface{}, len(cts)),
+	}
+
+	for i := range cts {
+		ms.names[i] = cts[i].Name()
+		ms.convs[i] = columnConverter(cts[i].DatabaseTypeName())
+		ms.dests[i] = &ms.vals[i]
+	}
+	return ms, nil
+}
+
+// row returns a new map holding converted values scanned into dests.
+func (ms *mapScanner) row() (map[string]interface{}, error) {
+	var err error
+
+	row := make(map[string]interface{}, len(ms.names))
+	for i := range ms.vals {
+		if ms.vals[i] == nil {
+			row[ms.names[i]] = nil
+			continue
+		}
+		if row[ms.names[i]], err = ms.convs[i](ms.vals[i]); err != nil {
+			return nil, errors.Catch(err).Set("column", ms.names[i]).Msg("dbw: column value conversion failed")
+		}
+	}
+	return row, nil
+}
+
+// FetchMaps reads all rows of the result as maps with column names as keys.
+// PostgreSQL values are converted to natural Go values: numeric to string,
+// json and jsonb to json.RawMessage, arrays to slices.
+func (si *StmtInstance) FetchMaps() ([]map[string]interface{}, error) {
+	var res []map[string]interface{}
+
+	err := si.FetchMapsFunc(func(row map[string]interface{}) error {
+		res = append(res, row)
+		return nil
+	}).Err()
+
+	return res, err
+}
+
+// FetchMapsFunc calls f for every row of the result. Row passes to f as
+// a new map with column names as keys and values converted like in FetchMaps.
+func (si *StmtInstance) FetchMapsFunc(f func(map[string]interface{}) error) *StmtInstance {
+
+	if si.err != nil {
+		return si
+	}
+
+	if si.rows == nil {
+		si.err = errors.New("FetchMapsFunc() requires Query() call")
+		return si
+	}
+
+	// rows are closed before StmtLogger.After to report close error.
+	defer si.Close()
+
+	ms, err := newMapScanner(si.rows)
+	if err != nil {
+		si.err = parseError(err)
+		return si
+	}
+
+	for si.rows.Next() {
+		if si.err = si.rows.Scan(ms.dests...); si.err != nil {
+			si.err = parseError(si.err)
+			return si
+		}
+
+		row, err := ms.row()
+		if err != nil {
+			si.err = err
+			return si
+		}
+
+		if f != nil {
+			if si.err = f(row); si.err != nil {
+				return si
+			}
+		}
+
+		si.RowsFetched++
+	}
+
+	if si.err = si.rows.Err(); si.err != nil {
+		si.err = parseError(si.err)
+		return si
+	}
+
+	si.RowsFetchedIn = time.Now().Sub(si.At.Add(si.RespondedIn))
+	return si
+}
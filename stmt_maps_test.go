@@ -0,0 +1,37 @@
+package dbw
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestColumnConverter(t *testing.T) {
+
+	tc := []struct {
+		dbType string
+		src    interface{}
+		dst    interface{}
+	}{
+		{"NUMERIC", []byte("12.50"), "12.50"},
+		{"JSONB", []byte(`{"a":1}`), json.RawMessage(`{"a":1}`)},
+		{"_INT4", []byte("{1,2,3}"), []int64{1, 2, 3}},
+		{"_TEXT", []byte(`{a,"b c"}`), []string{"a", "b c"}},
+		{"_FLOAT8", []byte("{1.5}"), []float64{1.5}},
+		{"UUID", []byte("0b7e5c3a-6d4a-4d2b-9bb7-4c1f3f6e7b11"), "0b7e5c3a-6d4a-4d2b-9bb7-4c1f3f6e7b11"},
+		{"BYTEA", []byte{1, 2}, []byte{1, 2}},
+		{"INT8", int64(7), int64(7)},
+	}
+
+	for i := range tc {
+		t.Run(tc[i].dbType, func(t *testing.T) {
+			res, err := columnConverter(tc[i].dbType)(tc[i].src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(res, tc[i].dst) {
+				t.Errorf("expected %#v, got %#v", tc[i].dst, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package dbw
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/axkit/errors"
+)
+
+var (
+	ErrInvalidStatements     = errors.New("invalid sql statements").StatusCode(500).Critical()
+	ErrDuplicateStatementUID = errors.New("duplicate statement uid").StatusCode(500).Critical()
+)
+
+// StatementRegistry collects named SQL statements to be prepared and
+// validated at once during application start.
+//
+//	var reg = dbw.NewStatementRegistry().
+//		Add("customerByEmail", "SELECT id, name FROM customers WHERE email = $1").
+//		Add("closeOrder", "UPDATE orders SET closed_at = now() WHERE id = $1")
+//
+//	if err := reg.Prepare(ctx, db, true).Err(); err != nil {
+//		log.Fatal().Err(err).Msg("invalid sql statements")
+//	}
+//
+// Prepared statements are taken later by uid using DB.Stmt.
+type StatementRegistry struct {
+	mux   sync.Mutex
+	uids  []string
+	stmts map[string]string
+	dups  map[string]string
+}
+
+// NewStatementRegistry returns empty registry.
+func NewStatementRegistry() *StatementRegistry {
+	return &StatementRegistry{stmts: make(map[string]string)}
+}
+
+// Add registers statement qry under uid. Registering different statements
+// under the same uid is reported as failure by Prepare.
+func (r *StatementRegistry) Add(uid, qry string) *StatementRegistry {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if prev, ok := r.stmts[uid]; ok {
+		if prev != qry {
+			if r.dups == nil {
+				r.dups = make(map[string]string)
+			}
+			r.dups[uid] = qry
+		}
+		return r
+	}
+
+	r.uids = append(r.uids, uid)
+	r.stmts[uid] = qry
+	return r
+}
+
+// UIDs returns uids of registered statements in order of registration.
+func (r *StatementRegistry) UIDs() []string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return append([]string(nil), r.uids...)
+}
+
+// SQL returns text of statement registered under uid.
+func (r *StatementRegistry) SQL(uid string) (string, bool) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	qry, ok := r.stmts[uid]
+	return qry, ok
+}
+
+// StatementFailure describes statement failed to be prepared.
+type StatementFailure struct {
+	UID string
+	SQL string
+
+	// Code holds SQLSTATE of the error, if it's raised by PostgreSQL.
+	Code string
+
+	// Message holds error message.
+	Message string
+
+	// Position holds 1-based character position of the error in SQL,
+	// Line and Column hold the same position as 1-based line and column.
+	// All are zero if position is unknown.
+	Position int
+	Line     int
+	Column   int
+
+	Err error
+}
+
+// StatementReport describes result of StatementRegistry.Prepare.
+type StatementReport struct {
+	// Prepared holds number of successfully prepared statements.
+	Prepared int
+
+	// Failures holds failed statements in order of registration.
+	Failures []StatementFailure
+}
+
+// Err returns nil if all statements are prepared successfully.
+func (sr *StatementReport) Err() error {
+	if len(sr.Failures) == 0 {
+		return nil
+	}
+
+	uids := make([]string, len(sr.Failures))
+	for i := range sr.Failures {
+		uids[i] = sr.Failures[i].UID
+	}
+
+	return ErrInvalidStatements.Capture().
+		Set("failed", len(sr.Failures)).
+		SetStrs("uids", uids...)
+}
+
+// String returns human readable report of failures, one per line.
+func (sr *StatementReport) String() string {
+	var sb strings.Builder
+	for i := range sr.Failures {
+		f := &sr.Failures[i]
+		sb.WriteString(f.UID)
+		if f.Code != "" {
+			sb.WriteString(" [" + f.Code + "]")
+		}
+		if f.Line > 0 {
+			sb.WriteString(" at " + strconv.Itoa(f.Line) + ":" + strconv.Itoa(f.Column))
+		}
+		sb.WriteString(": " + f.Message + "\n")
+	}
+	return sb.String()
+}
+
+// prepareWorkers limits number of statements prepared concurrently by
+// StatementRegistry if the database has no limit of open connections.
+const prepareWorkers = 8
+
+// Prepare prepares all registered statements in db using their uids as
+// names. Statements are prepared even if the database uses ExecUnprepared
+// mode to validate them. If parallel is true, statements are prepared
+// concurrently by not more than maximum number of open connections of the
+// database or 8 goroutines if there is no limit.
+func (r *StatementRegistry) Prepare(ctx context.Context, db *DB, parallel bool) *StatementReport {
+
+	r.mux.Lock()
+	uids := append([]string(nil), r.uids...)
+	stmts := make(map[string]string, len(r.stmts))
+	for k, v := range r.stmts {
+		stmts[k] = v
+	}
+	dups := r.dups
+	r.mux.Unlock()
+
+	ctx = WithExecMode(ctx, ExecPrepared)
+
+	errs := make([]error, len(uids))
+	f := func(i int) {
+		if qry, ok := dups[uids[i]]; ok {
+			errs[i] = ErrDuplicateStatementUID.Capture().Set("uid", uids[i]).Set("query", qry)
+			return
+		}
+		errs[i] = db.prepareContext(ctx, uids[i], stmts[uids[i]], "", true).Err()
+	}
+
+	if parallel {
+		workers := db.SQLDB().Stats().MaxOpenConnections
+		if workers <= 0 {
+			workers = prepareWorkers
+		}
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, workers)
+		wg.Add(len(uids))
+		for i := range uids {
+			sem <- struct{}{}
+			go func(i int) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				f(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range uids {
+			f(i)
+		}
+	}
+
+	res := StatementReport{}
+	for i := range uids {
+		if errs[i] == nil {
+			res.Prepared++
+			continue
+		}
+		res.Failures = append(res.Failures, newStatementFailure(uids[i], stmts[uids[i]], errs[i]))
+	}
+	return &res
+}
+
+func newStatementFailure(uid, qry string, err error) StatementFailure {
+
+	res := StatementFailure{UID: uid, SQL: qry, Message: err.Error(), Err: err}
+
+	pge := pqError(err)
+	if pge == nil {
+		return res
+	}
+
+	res.Code = string(pge.Code)
+	res.Message = pge.Message
+	res.Position, _ = strconv.Atoi(pge.Position)
+	// position refers to the text sent to the database, see DB.prepareContext.
+	res.Line, res.Column = lineColumn(strings.Trim(qry, "\n\t"), res.Position)
+	return res
+}
+
+// lineColumn converts 1-based character position in s to 1-based line and column.
+func lineColumn(s string, pos int) (line, col int) {
+	if pos <= 0 {
+		return 0, 0
+	}
+
+	line, col = 1, 1
+	for i, c := range []rune(s) {
+		if i == pos-1 {
+			break
+		}
+		if c == '\n' {
+			line++
+			col = 1
+			continue
+		}
+		col++
+	}
+	return line, col
+}
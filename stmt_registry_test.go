@@ -0,0 +1,135 @@
+package dbw
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/axkit/errors"
+	"github.com/lib/pq"
+)
+
+func TestStatementRegistry_Add(t *testing.T) {
+
+	r := NewStatementRegistry().
+		Add("a", "SELECT 1").
+		Add("b", "SELECT 2").
+		Add("a", "SELECT 1").
+		Add("b", "SELECT 3")
+
+	if uids := r.UIDs(); len(uids) != 2 || uids[0] != "a" || uids[1] != "b" {
+		t.Errorf("unexpected uids %v", uids)
+	}
+
+	if _, ok := r.dups["b"]; !ok || len(r.dups) != 1 {
+		t.Errorf("expected duplicate b, got %v", r.dups)
+	}
+}
+
+func TestNewStatementFailure(t *testing.T) {
+
+	qry := "\nSELECT id\n  FROM customer\n WHERE idd = $1"
+	err := &pq.Error{Code: "42703", Message: `column "idd" does not exist`, Position: "34"}
+
+	f := newStatementFailure("x", qry, err)
+	if f.Code != "42703" || f.Position != 34 || f.Line != 3 || f.Column != 8 {
+		t.Errorf("unexpected failure %+v", f)
+	}
+
+	sr := StatementReport{Failures: []StatementFailure{f}}
+	if sr.Err() == nil {
+		t.Error("expected error")
+	}
+	if s := sr.String(); s != "x [42703] at 3:8: column \"idd\" does not exist\n" {
+		t.Errorf("unexpected report %q", s)
+	}
+}
+
+func TestStatementRegistry_Prepare(t *testing.T) {
+
+	for _, parallel := range []bool{false, true} {
+		db, srv := newFakeDB(t, nil)
+		db.SetExecMode(ExecUnprepared)
+		srv.prepare = func(qry string) error {
+			if i := strings.Index(qry, "FORM"); i >= 0 {
+				return &pq.Error{Code: "42601", Message: `syntax error at or near "FORM"`, Position: strconv.Itoa(i + 1)}
+			}
+			return nil
+		}
+
+		r := NewStatementRegistry().
+			Add("a", "SELECT 1").
+			Add("b", "SELECT id FORM b").
+			Add("c", "SELECT 2").
+			Add("d", "SELECT 3").
+			Add("d", "SELECT 4").
+			Add("e", "SELECT\n  id FORM e")
+
+		rep := r.Prepare(context.Background(), db, parallel)
+		if rep.Prepared != 2 || len(rep.Failures) != 3 {
+			t.Fatalf("parallel %v: unexpected report %+v", parallel, rep)
+		}
+
+		exp := []StatementFailure{
+			{UID: "b", Code: "42601", Position: 11, Line: 1, Column: 11},
+			{UID: "d"},
+			{UID: "e", Code: "42601", Position: 13, Line: 2, Column: 6},
+		}
+		for i, f := range rep.Failures {
+			if f.UID != exp[i].UID || f.Code != exp[i].Code || f.Position != exp[i].Position ||
+				f.Line != exp[i].Line || f.Column != exp[i].Column {
+				t.Errorf("parallel %v: expected %+v, got %+v", parallel, exp[i], f)
+			}
+		}
+
+		if !errors.Is(rep.Failures[1].Err, ErrDuplicateStatementUID) {
+			t.Errorf("parallel %v: expected duplicate uid, got %v", parallel, rep.Failures[1].Err)
+		}
+		if !errors.Is(rep.Err(), ErrInvalidStatements) {
+			t.Errorf("parallel %v: expected ErrInvalidStatements, got %v", parallel, rep.Err())
+		}
+
+		for _, uid := range []string{"a", "c"} {
+			if s, ok := db.ps[uid]; !ok || !s.pinned {
+				t.Errorf("parallel %v: expected %s pinned in cache", parallel, uid)
+			}
+		}
+		if n := db.PreparedStatementCount(); n != 2 {
+			t.Errorf("parallel %v: expected 2 cached statements, got %d", parallel, n)
+		}
+	}
+}
+
+func TestStatementRegistry_PrepareBounded(t *testing.T) {
+
+	db, srv := newFakeDB(t, nil)
+
+	var cur, max int32
+	srv.prepare = func(string) error {
+		n := atomic.AddInt32(&cur, 1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&cur, -1)
+		return nil
+	}
+
+	r := NewStatementRegistry()
+	for i := 0; i < 3*prepareWorkers; i++ {
+		r.Add(strconv.Itoa(i), "SELECT "+strconv.Itoa(i))
+	}
+
+	if err := r.Prepare(context.Background(), db, true).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if max > prepareWorkers {
+		t.Errorf("expected not more than %d concurrent prepares, got %d", prepareWorkers, max)
+	}
+}
@@ -0,0 +1,257 @@
+package dbw
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/axkit/errors"
+)
+
+// StatsBuckets holds upper bounds of statement latency histogram buckets.
+// The last histogram bucket counts executions longer than the last bound.
+var StatsBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// StmtStats holds runtime statistics of a prepared statement.
+type StmtStats struct {
+	UID string
+	SQL string
+
+	// Calls holds number of statement executions.
+	Calls uint64
+
+	// Errors holds number of failed executions. Not found results
+	// are not counted as errors.
+	Errors uint64
+
+	// RowsFetched holds total number of fetched rows.
+	RowsFetched uint64
+
+	// TotalTime, MinTime and MaxTime hold execution latency, including
+	// fetching of rows.
+	TotalTime time.Duration
+	MinTime   time.Duration
+	MaxTime   time.Duration
+
+	// Histogram holds number of executions by latency buckets defined by
+	// StatsBuckets. Histogram[len(StatsBuckets)] counts slower executions.
+	Histogram []uint64
+}
+
+// AvgTime returns average execution latency.
+func (ss *StmtStats) AvgTime() time.Duration {
+	if ss.Calls == 0 {
+		return 0
+	}
+	return ss.TotalTime / time.Duration(ss.Calls)
+}
+
+// stmtStats accumulates statistics of the statement.
+type stmtStats struct {
+	mux sync.Mutex
+	StmtStats
+}
+
+func (s *stmtStats) add(d time.Duration, rows int, err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.Histogram == nil {
+		s.Histogram = make([]uint64, len(StatsBuckets)+1)
+	}
+
+	s.Calls++
+	if err != nil && !errors.IsNotFound(err) {
+		s.Errors++
+	}
+	s.RowsFetched += uint64(rows)
+	s.TotalTime += d
+	if s.Calls == 1 || d < s.MinTime {
+		s.MinTime = d
+	}
+	if d > s.MaxTime {
+		s.MaxTime = d
+	}
+
+	s.Histogram[bucket(d)]++
+}
+
+func (s *stmtStats) snapshot() StmtStats {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	res := s.StmtStats
+	res.Histogram = make([]uint64, len(StatsBuckets)+1)
+	copy(res.Histogram, s.Histogram)
+	return res
+}
+
+// Stats returns runtime statistics of the statement.
+func (s *Stmt) Stats() StmtStats {
+	res := s.stats.snapshot()
+	res.UID = s.uid
+	res.SQL = s.text
+	return res
+}
+
+// StmtStatsList is a list of statement statistics.
+type StmtStatsList []StmtStats
+
+// SortByTotalTime sorts the list by total execution time, descending.
+func (l StmtStatsList) SortByTotalTime() StmtStatsList {
+	sort.SliceStable(l, func(i, j int) bool { return l[i].TotalTime > l[j].TotalTime })
+	return l
+}
+
+// SortByCalls sorts the list by number of executions, descending.
+func (l StmtStatsList) SortByCalls() StmtStatsList {
+	sort.SliceStable(l, func(i, j int) bool { return l[i].Calls > l[j].Calls })
+	return l
+}
+
+// SortByMaxTime sorts the list by maximal execution time, descending.
+func (l StmtStatsList) SortByMaxTime() StmtStatsList {
+	sort.SliceStable(l, func(i, j int) bool { return l[i].MaxTime > l[j].MaxTime })
+	return l
+}
+
+// TxStats holds statistics of finished transactions.
+type TxStats struct {
+	Commits   uint64
+	Rollbacks uint64
+
+	// TotalTime holds total duration of finished transactions.
+	TotalTime time.Duration
+
+	// Histogram holds number of transactions by duration buckets defined by
+	// StatsBuckets. Histogram[len(StatsBuckets)] counts longer transactions.
+	Histogram []uint64
+}
+
+// txStats accumulates statistics of transactions.
+type txStats struct {
+	mux sync.Mutex
+	TxStats
+}
+
+func (s *txStats) add(d time.Duration, committed bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.Histogram == nil {
+		s.Histogram = make([]uint64, len(StatsBuckets)+1)
+	}
+
+	if committed {
+		s.Commits++
+	} else {
+		s.Rollbacks++
+	}
+	s.TotalTime += d
+	s.Histogram[bucket(d)]++
+}
+
+func (s *txStats) snapshot() TxStats {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	res := s.TxStats
+	res.Histogram = make([]uint64, len(StatsBuckets)+1)
+	copy(res.Histogram, s.Histogram)
+	return res
+}
+
+// errStats counts errors by SQLSTATE class.
+type errStats struct {
+	mux     sync.Mutex
+	classes map[string]uint64
+}
+
+func (s *errStats) add(err error) {
+	if err == nil || errors.IsNotFound(err) {
+		return
+	}
+
+	class := SQLStateClass(err)
+	if class == "" {
+		class = "other"
+	}
+
+	s.mux.Lock()
+	if s.classes == nil {
+		s.classes = make(map[string]uint64)
+	}
+	s.classes[class]++
+	s.mux.Unlock()
+}
+
+func (s *errStats) snapshot() map[string]uint64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	res := make(map[string]uint64, len(s.classes))
+	for k, v := range s.classes {
+		res[k] = v
+	}
+	return res
+}
+
+func bucket(d time.Duration) int {
+	return sort.Search(len(StatsBuckets), func(i int) bool { return d <= StatsBuckets[i] })
+}
+
+// Stats holds snapshot of database statistics.
+type Stats struct {
+	At time.Time
+
+	// Pool holds connection pool statistics.
+	Pool sql.DBStats
+
+	// Statements holds statistics of prepared statements sorted by
+	// total execution time.
+	Statements StmtStatsList
+
+	// Tx holds statistics of transactions.
+	Tx TxStats
+
+	// Errors holds number of failed statements and transactions by
+	// SQLSTATE class (first two characters of the code). Errors without
+	// SQLSTATE are counted as "other".
+	Errors map[string]uint64
+
+	// Cache holds prepared statement cache counters.
+	Cache StmtCacheStats
+}
+
+// StatementStats returns snapshot of prepared statements, transactions
+// and connection pool statistics.
+func (db *DB) StatementStats() *Stats {
+
+	res := Stats{At: time.Now()}
+	if db.sqldb != nil {
+		res.Pool = db.sqldb.Stats()
+	}
+
+	db.mux.RLock()
+	res.Statements = make(StmtStatsList, 0, len(db.ps))
+	for _, s := range db.ps {
+		res.Statements = append(res.Statements, s.Stats())
+	}
+	db.mux.RUnlock()
+
+	res.Statements.SortByTotalTime()
+	res.Tx = db.txStats.snapshot()
+	res.Errors = db.errStats.snapshot()
+	res.Cache = db.StmtCacheStats()
+	return &res
+}
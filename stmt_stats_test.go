@@ -0,0 +1,42 @@
+package dbw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/axkit/errors"
+)
+
+func TestStmtStats_add(t *testing.T) {
+
+	var s stmtStats
+	s.add(2*time.Millisecond, 10, nil)
+	s.add(20*time.Millisecond, 0, errors.New("failed"))
+	s.add(10*time.Second, 0, errors.NotFound("row not found"))
+
+	ss := s.snapshot()
+	if ss.Calls != 3 || ss.Errors != 1 || ss.RowsFetched != 10 {
+		t.Errorf("unexpected counters %+v", ss)
+	}
+
+	if ss.MinTime != 2*time.Millisecond || ss.MaxTime != 10*time.Second {
+		t.Errorf("unexpected min/max %v/%v", ss.MinTime, ss.MaxTime)
+	}
+
+	exp := []uint64{0, 1, 0, 1, 0, 0, 0, 0, 1}
+	for i := range exp {
+		if ss.Histogram[i] != exp[i] {
+			t.Fatalf("expected histogram %v, got %v", exp, ss.Histogram)
+		}
+	}
+}
+
+func TestStmtStatsList_SortByTotalTime(t *testing.T) {
+
+	l := StmtStatsList{{UID: "a", TotalTime: 1}, {UID: "b", TotalTime: 3}, {UID: "c", TotalTime: 2}}
+	l.SortByTotalTime()
+
+	if l[0].UID != "b" || l[1].UID != "c" || l[2].UID != "a" {
+		t.Errorf("unexpected order %v", l)
+	}
+}
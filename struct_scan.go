@@ -0,0 +1,378 @@
+package dbw
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/axkit/errors"
+)
+
+// TagCol holds tag key overriding column name of the struct field.
+// Example: `dbw:"col=customer_name"`.
+const TagCol = "col"
+
+var (
+	// ErrUnmappedColumn returns by ScanStruct and FetchStructs if result
+	// column has no corresponding struct field.
+	ErrUnmappedColumn = errors.New("unmapped column").StatusCode(500)
+
+	// ErrInvalidScanTarget returns if scan target is not pointer to struct or
+	// pointer to slice of structs.
+	ErrInvalidScanTarget = errors.New("invalid scan target").StatusCode(500)
+)
+
+// ScanOption describes how result columns are mapped to struct fields.
+type ScanOption struct {
+	ignoreUnmapped bool
+}
+
+// WithIgnoreUnmapped makes ScanStruct and FetchStructs skip result columns
+// having no corresponding struct field.
+func WithIgnoreUnmapped() func(*ScanOption) {
+	return func(o *ScanOption) {
+		o.ignoreUnmapped = true
+	}
+}
+
+// TagPrefix holds tag key defining column name prefix of nested struct fields.
+// Example: `dbw:"prefix=c_"`. Nested struct fields without the tag
+// are mapped to columns prefixed by snake case field name and "__".
+const TagPrefix = "prefix"
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// structField describes struct field mapped to a result column.
+type structField struct {
+	// index holds field index path from the root struct.
+	index []int
+
+	// nullable is true if the field belongs to nested struct referenced by
+	// pointer. The pointer stays nil if all columns of the struct are NULL.
+	nullable bool
+}
+
+// structFieldsCache holds column names to struct fields by struct type.
+var structFieldsCache sync.Map
+
+// structFields returns struct fields by column name. Column name is snake
+// case field name or value of tag key "col" prefixed by nested struct prefix.
+func structFields(typ reflect.Type) map[string]structField {
+	if res, ok := structFieldsCache.Load(typ); ok {
+		return res.(map[string]structField)
+	}
+
+	res := make(map[string]structField)
+	collectStructFields(typ, "", nil, false, res)
+	structFieldsCache.Store(typ, res)
+	return res
+}
+
+func collectStructFields(typ reflect.Type, prefix string, index []int, nullable bool, res map[string]structField) {
+
+	for i := 0; i < typ.NumField(); i++ {
+		tf := typ.Field(i)
+
+		// ignore private fields.
+		if tf.PkgPath != "" && !tf.Anonymous {
+			continue
+		}
+
+		tag := tf.Tag.Get(FieldTagLabel)
+		if tag == "-" {
+			continue
+		}
+
+		idx := make([]int, len(index)+1)
+		copy(idx, index)
+		idx[len(index)] = i
+
+		ft := tf.Type
+		isPtr := ft.Kind() == reflect.Ptr
+		if isPtr {
+			ft = ft.Elem()
+		}
+
+		if isNestedStruct(ft) {
+			p, ok := tagValue(tag, TagPrefix)
+			switch {
+			case ok:
+				collectStructFields(ft, prefix+p, idx, nullable || isPtr, res)
+				continue
+			case tf.Anonymous:
+				collectStructFields(ft, prefix, idx, nullable, res)
+				continue
+			case tf.PkgPath == "":
+				collectStructFields(ft, prefix+ToSnakeCase(tf.Name)+"__", idx, nullable || isPtr, res)
+				continue
+			}
+		}
+
+		if tf.PkgPath != "" {
+			continue
+		}
+
+		name, ok := tagValue(tag, TagCol)
+		if !ok || name == "" {
+			name = ToSnakeCase(tf.Name)
+		}
+		name = prefix + name
+
+		// fields of outer struct take precedence over embedded ones.
+		if prev, ok := res[name]; ok && len(prev.index) <= len(idx) {
+			continue
+		}
+		res[name] = structField{index: idx, nullable: nullable}
+	}
+}
+
+// isNestedStruct returns true if struct type typ is mapped to several
+// columns rather than scanned from a single one.
+func isNestedStruct(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct &&
+		typ != timeType &&
+		!reflect.PtrTo(typ).Implements(scannerType)
+}
+
+// tagValue returns value of key in struct field tag like `dbw:"col=name,noins"`.
+func tagValue(tag, key string) (string, bool) {
+	for _, kv := range strings.Split(tag, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == key {
+			return "", true
+		}
+		if strings.HasPrefix(kv, key+"=") {
+			return kv[len(key)+1:], true
+		}
+	}
+	return "", false
+}
+
+// scanPlan returns struct field for every column. Field index path is nil
+// for unmapped column if ignoreUnmapped is true.
+func scanPlan(typ reflect.Type, cols []string, ignoreUnmapped bool) ([]structField, error) {
+
+	fields := structFields(typ)
+	res := make([]structField, len(cols))
+
+	for i := range cols {
+		f, ok := fields[strings.ToLower(cols[i])]
+		if !ok {
+			if ignoreUnmapped {
+				continue
+			}
+			return nil, ErrUnmappedColumn.Capture().Set("column", cols[i]).Set("type", typ.String())
+		}
+		res[i] = f
+	}
+	return res, nil
+}
+
+// scanDests returns scan destinations for struct v according to plan.
+// Nil pointers to embedded structs are allocated. Nullable fields are
+// scanned into temporary values and assigned by scanAssign.
+func scanDests(v reflect.Value, plan []structField) []interface{} {
+	res := make([]interface{}, len(plan))
+	for i := range plan {
+		switch {
+		case plan[i].index == nil:
+			res[i] = new(interface{})
+		case plan[i].nullable:
+			res[i] = reflect.New(reflect.PtrTo(fieldType(v.Type(), plan[i].index))).Interface()
+		default:
+			res[i] = fieldByIndexAlloc(v, plan[i].index).Addr().Interface()
+		}
+	}
+	return res
+}
+
+// scanAssign copies not NULL nullable values from dests to struct v,
+// allocating nested structs on the way.
+func scanAssign(v reflect.Value, plan []structField, dests []interface{}) {
+	for i := range plan {
+		if !plan[i].nullable {
+			continue
+		}
+		ptr := reflect.ValueOf(dests[i]).Elem()
+		if ptr.IsNil() {
+			continue
+		}
+		fieldByIndexAlloc(v, plan[i].index).Set(ptr.Elem())
+	}
+}
+
+func fieldType(typ reflect.Type, index []int) reflect.Type {
+	for _, x := range index {
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		typ = typ.Field(x).Type
+	}
+	return typ
+}
+
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// structType returns struct type of dst being pointer to struct.
+func structType(dst interface{}) (reflect.Type, bool) {
+	typ := reflect.TypeOf(dst)
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return nil, false
+	}
+	return typ.Elem(), true
+}
+
+// ScanStruct reads the first row of the result into struct referenced by dst
+// and closes the result. Columns are mapped to struct fields by snake case
+// field name or by tag key "col". Fields of nested structs are mapped to
+// columns with prefix (see TagPrefix), nested struct referenced by pointer
+// stays nil if all its columns are NULL. Requires instance created by Query()
+// or QueryContext(). Use FetchStructs or Iter to read many rows.
+func (si *StmtInstance) ScanStruct(dst interface{}, optFunc ...func(*ScanOption)) error {
+	if si.err != nil {
+		return si.err
+	}
+
+	if si.rows == nil {
+		si.err = errors.New("ScanStruct() requires Query() call")
+		return si.err
+	}
+	defer si.Close()
+
+	option := ScanOption{}
+	for i := range optFunc {
+		optFunc[i](&option)
+	}
+
+	typ, ok := structType(dst)
+	if !ok || reflect.ValueOf(dst).IsNil() {
+		si.err = ErrInvalidScanTarget.Capture().Set("type", fmt.Sprintf("%T", dst))
+		return si.err
+	}
+
+	cols, err := si.rows.Columns()
+	if err != nil {
+		si.err = parseError(err)
+		return si.err
+	}
+
+	plan, err := scanPlan(typ, cols, option.ignoreUnmapped)
+	if err != nil {
+		si.err = err
+		return si.err
+	}
+
+	if !si.rows.Next() {
+		if si.err = si.rows.Err(); si.err != nil {
+			si.err = parseError(si.err)
+			return si.err
+		}
+		si.err = parseError(sql.ErrNoRows)
+		return si.err
+	}
+
+	v := reflect.ValueOf(dst).Elem()
+	dests := scanDests(v, plan)
+	if si.err = si.rows.Scan(dests...); si.err != nil {
+		si.err = parseError(si.err)
+		return si.err
+	}
+	scanAssign(v, plan, dests)
+
+	si.RowsFetched++
+	return nil
+}
+
+// FetchStructs reads all rows of the result and appends them into slice
+// referenced by arr. Slice element can be struct or pointer to struct.
+// Columns are mapped to struct fields like in ScanStruct.
+func (si *StmtInstance) FetchStructs(arr interface{}, optFunc ...func(*ScanOption)) *StmtInstance {
+
+	if si.err != nil {
+		return si
+	}
+
+	if si.rows == nil {
+		si.err = errors.New("FetchStructs() requires Query() call")
+		return si
+	}
+
+	// rows are closed before StmtLogger.After to report close error.
+	defer si.Close()
+
+	option := ScanOption{}
+	for i := range optFunc {
+		optFunc[i](&option)
+	}
+
+	sv := reflect.ValueOf(arr)
+	if sv.Kind() != reflect.Ptr || sv.Elem().Kind() != reflect.Slice {
+		si.err = ErrInvalidScanTarget.Capture().Set("type", sv.Type().String())
+		return si
+	}
+	sv = sv.Elem()
+
+	et := sv.Type().Elem()
+	isPtr := et.Kind() == reflect.Ptr
+	if isPtr {
+		et = et.Elem()
+	}
+	if et.Kind() != reflect.Struct {
+		si.err = ErrInvalidScanTarget.Capture().Set("type", sv.Type().String())
+		return si
+	}
+
+	cols, err := si.rows.Columns()
+	if err != nil {
+		si.err = parseError(err)
+		return si
+	}
+
+	plan, err := scanPlan(et, cols, option.ignoreUnmapped)
+	if err != nil {
+		si.err = err
+		return si
+	}
+
+	for si.rows.Next() {
+		elem := reflect.New(et)
+		dests := scanDests(elem.Elem(), plan)
+		if si.err = si.rows.Scan(dests...); si.err != nil {
+			si.err = parseError(si.err)
+			return si
+		}
+		scanAssign(elem.Elem(), plan, dests)
+
+		if isPtr {
+			sv.Set(reflect.Append(sv, elem))
+		} else {
+			sv.Set(reflect.Append(sv, elem.Elem()))
+		}
+		si.RowsFetched++
+	}
+
+	if si.err = si.rows.Err(); si.err != nil {
+		si.err = parseError(si.err)
+		return si
+	}
+
+	si.RowsFetchedIn = time.Now().Sub(si.At.Add(si.RespondedIn))
+	return si
+}
@@ -0,0 +1,165 @@
+package dbw
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/axkit/errors"
+)
+
+func TestScanPlan(t *testing.T) {
+
+	type Base struct {
+		ID   int
+		Name string
+	}
+
+	type Row struct {
+		*Base
+		CustomerName string `dbw:"col=customer"`
+		TotalAmount  int
+		Ignored      string `dbw:"-"`
+		private      int
+	}
+
+	typ := reflect.TypeOf(Row{})
+
+	plan, err := scanPlan(typ, []string{"id", "customer", "TOTAL_AMOUNT", "name"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []structField{{index: []int{0, 0}}, {index: []int{1}}, {index: []int{2}}, {index: []int{0, 1}}}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Errorf("expected %v, got %v", expected, plan)
+	}
+
+	var row Row
+	dests := scanDests(reflect.ValueOf(&row).Elem(), plan)
+	*(dests[3].(*string)) = "Robert"
+	if row.Base == nil || row.Name != "Robert" {
+		t.Error("expected embedded struct allocated")
+	}
+
+	if _, err := scanPlan(typ, []string{"id", "ignored"}, false); err == nil {
+		t.Error("expected unmapped column error")
+	}
+
+	plan, err = scanPlan(typ, []string{"id", "private"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan[1].index != nil {
+		t.Errorf("expected unmapped column skipped, got %v", plan[1])
+	}
+}
+
+func TestScanPlan_Nested(t *testing.T) {
+
+	type Customer struct {
+		ID   int
+		Name string
+	}
+
+	type Manager struct {
+		ID    int
+		Email string
+	}
+
+	type Order struct {
+		ID        int
+		Customer  Customer
+		Manager   *Manager `dbw:"prefix=m_"`
+		CreatedAt NullTime
+	}
+
+	cols := []string{"id", "customer__id", "customer__name", "m_id", "m_email", "created_at"}
+	plan, err := scanPlan(reflect.TypeOf(Order{}), cols, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !plan[3].nullable || !plan[4].nullable || plan[1].nullable {
+		t.Errorf("unexpected nullable flags %v", plan)
+	}
+
+	// simulates rows.Scan() of outer joined row without manager.
+	var row Order
+	v := reflect.ValueOf(&row).Elem()
+	dests := scanDests(v, plan)
+	*(dests[2].(*string)) = "ACME"
+	scanAssign(v, plan, dests)
+
+	if row.Customer.Name != "ACME" {
+		t.Errorf("expected customer name, got %q", row.Customer.Name)
+	}
+	if row.Manager != nil {
+		t.Error("expected nil manager if all its columns are NULL")
+	}
+
+	// simulates rows.Scan() of row with manager.
+	dests = scanDests(v, plan)
+	email := "a@b.c"
+	*(dests[4].(**string)) = &email
+	scanAssign(v, plan, dests)
+
+	if row.Manager == nil || row.Manager.Email != email {
+		t.Errorf("expected manager allocated, got %v", row.Manager)
+	}
+}
+
+func TestStmtInstance_ScanStruct(t *testing.T) {
+
+	db, srv := newFakeDB(t, iterServer(2, 0, nil))
+	l := &recStmtLogger{}
+	db.SetStmtLogger(l)
+
+	var row iterRow
+	if err := db.QueryContext(context.Background(), "SELECT id, name FROM items").ScanStruct(&row); err != nil {
+		t.Fatal(err)
+	}
+
+	if row.ID != 1 || row.Name != "name" {
+		t.Errorf("expected first row, got %+v", row)
+	}
+
+	if !srv.allClosed() {
+		t.Error("expected result closed after the first row")
+	}
+
+	if len(l.after) != 1 {
+		t.Errorf("expected StmtLogger.After called once, got %d", len(l.after))
+	}
+}
+
+func TestStmtInstance_ScanStructNotFound(t *testing.T) {
+
+	db, srv := newFakeDB(t, iterServer(0, 0, nil))
+
+	var row iterRow
+	err := db.QueryContext(context.Background(), "SELECT id, name FROM items").ScanStruct(&row)
+	if !errors.IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	if !srv.allClosed() {
+		t.Error("expected result closed")
+	}
+}
+
+func TestStmtInstance_ScanStructNil(t *testing.T) {
+
+	db, srv := newFakeDB(t, iterServer(1, 0, nil))
+
+	for _, dst := range []interface{}{nil, (*iterRow)(nil)} {
+		err := db.QueryContext(context.Background(), "SELECT id, name FROM items").ScanStruct(dst)
+		if !errors.Is(err, ErrInvalidScanTarget) {
+			t.Errorf("%T: expected ErrInvalidScanTarget, got %v", dst, err)
+		}
+	}
+
+	if !srv.allClosed() {
+		t.Error("expected results closed")
+	}
+}
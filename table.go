@@ -78,6 +78,7 @@ func New(ctx context.Context, db *DB, name string, model interface{}) (*Table, e
 	}
 
 	t.initColTag(model)
+	t.registerTaggedColumns()
 	t.columns = t.fieldNames(model, "", All)
 
 	t.withDeletedAt = strings.Contains(t.columns, "deleted_at")
@@ -101,6 +102,7 @@ func NewTable(db *DB, name string, model interface{}) *Table {
 	//t.SetLogger(db.Logger())
 
 	t.initColTag(model)
+	t.registerTaggedColumns()
 	t.columns = t.fieldNames(model, "", All)
 
 	t.withDeletedAt = strings.Contains(t.columns, "deleted_at")
@@ -246,7 +248,7 @@ func (t *Table) doUpdateTx(ctx context.Context, tx *Tx, row interface{}, tags st
 	if !ok {
 		// если не найдено в подготовленных запросах.
 		qry = t.genUpdateSQL(t.fieldNamesUpdate(row, tags, rule))
-		stmt = t.db.PrepareContextN(ctx, qry, stmtUID)
+		stmt = t.db.prepareContext(ctx, stmtUID, qry, t.name, true)
 	}
 
 	if stmt.err != nil {
@@ -272,7 +274,7 @@ func (t *Table) doUpdateTx(ctx context.Context, tx *Tx, row interface{}, tags st
 	}
 
 	if t.isAuditRequired && t.log != nil {
-		t.log.Info().Str("qry", stmt.text).Interface("params", addrs).Msg("sql")
+		t.log.Info().Str("qry", stmt.text).Interface("params", t.db.redact(stmt.text, addrs)).Msg("sql")
 	}
 
 	if t.withRowVersion {
@@ -317,7 +319,7 @@ func (t *Table) doSoftDeleteTx(ctx context.Context, tx *Tx, id interface{}, dele
 		stmt *Stmt
 	)
 
-	if stmt = t.db.PrepareContext(ctx, t.SQL.SoftDeleteByID); stmt.Err() != nil {
+	if stmt = t.prepareContext(ctx, t.SQL.SoftDeleteByID); stmt.Err() != nil {
 		return stmt.Err()
 	}
 
@@ -365,7 +367,7 @@ func (t *Table) doHardDeleteTxCtx(ctx context.Context, tx *Tx, id interface{}) e
 		stmt *Stmt
 	)
 
-	if stmt = t.db.PrepareContext(ctx, t.SQL.HardDeleteByID); stmt.Err() != nil {
+	if stmt = t.prepareContext(ctx, t.SQL.HardDeleteByID); stmt.Err() != nil {
 		return stmt.Err()
 	}
 
@@ -401,7 +403,8 @@ func (t *Table) doHardDelTxCtx(ctx context.Context, tx *Tx, where string, args .
 		stmt *Stmt
 	)
 
-	if stmt = t.db.PrepareContext(ctx, t.SQL.HardFlexDelete+where); stmt.Err() != nil {
+	qry, args := t.db.expandSlices(ctx, t.SQL.HardFlexDelete+where, args)
+	if stmt = t.prepareContext(ctx, qry); stmt.Err() != nil {
 		return nil, stmt.Err()
 	}
 
@@ -445,7 +448,7 @@ func (t *Table) doInsertTxCtx(ctx context.Context, tx *Tx, row interface{}, retu
 		ctx = t.ctx
 	}
 
-	if stmt = t.db.PrepareContext(ctx, t.SQL.Insert); stmt.Err() != nil {
+	if stmt = t.prepareContext(ctx, t.SQL.Insert); stmt.Err() != nil {
 		return stmt.Err()
 	}
 
@@ -519,6 +522,19 @@ func (t *Table) doSelectCtx(ctx context.Context, where, order string, offset, li
 func (t *Table) doSelectCtxTx(ctx context.Context, tx *Tx, where, order string, offset, limit int, f func() error, row interface{}, params ...interface{}) error {
 	cols := t.fieldAddrsSelect(row, "", All)
 
+	qry, params, err := t.selectQuery(ctx, tx, where, order, offset, limit, params...)
+	if err != nil {
+		return err
+	}
+
+	return t.instance(ctx, tx, qry).QueryContext(ctx, params...).Fetch(f, cols...).Err()
+}
+
+// selectQuery builds SELECT statement. RowLock found in params is removed
+// from them and added to the statement as locking clause. Slice parameters
+// are expanded according to slice mode of ctx.
+func (t *Table) selectQuery(ctx context.Context, tx *Tx, where, order string, offset, limit int, params ...interface{}) (string, []interface{}, error) {
+
 	qry := t.SQL.Select
 	if len(where) > 0 {
 		qry += " WHERE " + where
@@ -536,10 +552,14 @@ func (t *Table) doSelectCtxTx(ctx context.Context, tx *Tx, where, order string,
 		qry += " LIMIT " + strconv.Itoa(limit)
 	}
 
-	if tx == nil {
-		return t.db.QueryContext(ctx, qry, params...).Fetch(f, cols...).Err()
+	lock, params := extractRowLock(params)
+	lc, err := lockClause(tx, lock)
+	if err != nil {
+		return "", nil, err
 	}
-	return t.db.QueryContextTx(ctx, tx, qry, params...).Fetch(f, cols...).Err()
+
+	qry, params = t.db.expandSlices(ctx, qry+lc, params)
+	return qry, params, nil
 }
 
 // Count returns amount of rows in the table complaints with condition in where.
@@ -553,8 +573,11 @@ func (t *Table) count(ctx context.Context, tx *Tx, where string, params ...inter
 	if len(where) > 0 {
 		where = " WHERE " + where
 	}
-	err := t.db.QueryRow(t.SQL.SelectCount+where, params...).Scan(&cnt)
-	return cnt, err
+	qry := t.SQL.SelectCount + where
+	if err := t.db.QueryRow(qry, params...).Scan(&cnt); err != nil {
+		return 0, errors.Catch(err).Set("query", qry)
+	}
+	return cnt, nil
 }
 
 func (t *Table) DoUpdateRowVersionCtx(ctx context.Context, id interface{}, updatedAt *NullTime, rowVersion *int) error {
@@ -576,7 +599,7 @@ func (t *Table) doUpdateRowVersion(ctx context.Context, tx *Tx, id interface{},
 		stmt *Stmt
 	)
 
-	if stmt = t.db.PrepareContext(ctx, t.SQL.UpdateRowVersion); stmt.Err() != nil {
+	if stmt = t.prepareContext(ctx, t.SQL.UpdateRowVersion); stmt.Err() != nil {
 		return stmt.Err()
 	}
 
@@ -605,6 +628,16 @@ func (t *Table) DoSelectByID(id interface{}, row interface{}) error {
 	return WrapError(t, t.doSelectByIDCtx(t.ctx, id, row))
 }
 
+// DoSelectByIDTx reads row by id in transaction tx. Optional lock
+// appends locking clause like FOR UPDATE.
+func (t *Table) DoSelectByIDTx(tx *Tx, id interface{}, row interface{}, lock ...RowLock) error {
+	var rl RowLock
+	if len(lock) > 0 {
+		rl = lock[0]
+	}
+	return WrapError(t, t.doSelectByIDCtxTx(t.ctx, tx, id, row, rl))
+}
+
 func (t *Table) Columns() string {
 	return t.columns
 }
@@ -612,3 +645,9 @@ func (t *Table) Columns() string {
 func (t *Table) DoSelectRow(where string, row interface{}, args ...interface{}) error {
 	return WrapError(t, t.doSelectRowCtx(t.ctx, where, row, args...))
 }
+
+// DoSelectRowTx reads a single row in transaction tx. RowLock passed among
+// args appends locking clause.
+func (t *Table) DoSelectRowTx(tx *Tx, where string, row interface{}, args ...interface{}) error {
+	return WrapError(t, t.doSelectRowCtxTx(t.ctx, tx, where, row, args...))
+}
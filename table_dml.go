@@ -178,7 +178,7 @@ func (t *Table) insert(row interface{}, option Option) error {
 		option.ctx = context.Background()
 	}
 
-	stmt = t.db.PrepareContext(option.ctx, qry)
+	stmt = t.prepareContext(option.ctx, qry)
 
 	if err := stmt.Err(); err != nil {
 		return err
@@ -263,7 +263,7 @@ func (t *Table) delete(option *Option) error {
 
 	fmt.Println(qry)
 
-	stmt = t.db.PrepareContext(option.ctx, qry)
+	stmt = t.prepareContext(option.ctx, qry)
 
 	if err := stmt.Err(); err != nil {
 		return err
@@ -454,7 +454,7 @@ func (t *Table) update(row interface{}, option *Option) error {
 
 	fmt.Println("update qry=", qry)
 	return nil
-	stmt = t.db.PrepareContext(option.ctx, qry)
+	stmt = t.prepareContext(option.ctx, qry)
 
 	if err := stmt.Err(); err != nil {
 		return err
@@ -0,0 +1,293 @@
+package dbw
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/axkit/errors"
+	"github.com/lib/pq"
+)
+
+// ErrInvalidLoaderID returns by Loader.Load if id is nil or can't be
+// converted to type of model's field ID.
+var ErrInvalidLoaderID = errors.New("invalid loader id").StatusCode(400)
+
+const (
+	// DefaultLoaderWait holds default time window while Loader collects keys.
+	DefaultLoaderWait = 2 * time.Millisecond
+
+	// DefaultLoaderBatchSize holds default maximum amount of keys in a single query.
+	DefaultLoaderBatchSize = 100
+)
+
+type loaderCtxKey struct {
+	t *Table
+}
+
+// LoaderOption describes Loader parameters.
+type LoaderOption struct {
+	wait      time.Duration
+	batchSize int
+}
+
+// WithLoaderWait sets time window while Loader collects keys before the query.
+func WithLoaderWait(d time.Duration) func(*LoaderOption) {
+	return func(o *LoaderOption) {
+		o.wait = d
+	}
+}
+
+// WithLoaderBatchSize sets maximum amount of keys requested by a single query.
+func WithLoaderBatchSize(n int) func(*LoaderOption) {
+	return func(o *LoaderOption) {
+		o.batchSize = n
+	}
+}
+
+// Loader coalesces concurrent by-ID lookups into a single
+// SELECT ... WHERE id = ANY($1) query. Identical keys requested
+// within one batch are queried once.
+//
+// Loader is expected to live as long as a single request (GraphQL
+// resolvers tree, for instance). It does not cache rows between batches.
+type Loader struct {
+	t         *Table
+	ctx       context.Context
+	wait      time.Duration
+	batchSize int
+
+	// idType holds type of model's field ID. Requested keys are
+	// converted to the type for deduplication.
+	idType reflect.Type
+
+	// fetch reads rows by keys and returns them indexed by key.
+	fetch func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error)
+
+	mux   sync.Mutex
+	batch *loaderBatch
+}
+
+type loaderBatch struct {
+	keys  []interface{}
+	pos   map[interface{}]int
+	timer *time.Timer
+	rows  map[interface{}]interface{}
+	err   error
+	done  chan struct{}
+}
+
+// NewLoader creates Loader bound to the table. Context ctx is used for
+// the batch queries.
+func (t *Table) NewLoader(ctx context.Context, optFunc ...func(*LoaderOption)) *Loader {
+	option := LoaderOption{wait: DefaultLoaderWait, batchSize: DefaultLoaderBatchSize}
+	for i := range optFunc {
+		optFunc[i](&option)
+	}
+
+	l := &Loader{
+		t:         t,
+		ctx:       ctx,
+		wait:      option.wait,
+		batchSize: option.batchSize,
+	}
+
+	if f, ok := reflect.TypeOf(t.model).Elem().FieldByName(PrimaryKeyFieldName); ok {
+		l.idType = f.Type
+	}
+	l.fetch = l.fetchByIDs
+	return l
+}
+
+// WithLoader returns copy of ctx holding a new Loader of the table.
+// Use DoSelectByIDCtx with returned context to get by-ID lookups coalesced.
+func (t *Table) WithLoader(ctx context.Context, optFunc ...func(*LoaderOption)) context.Context {
+	return context.WithValue(ctx, loaderCtxKey{t}, t.NewLoader(ctx, optFunc...))
+}
+
+// LoaderFromContext returns the table's Loader attached to ctx by WithLoader.
+func (t *Table) LoaderFromContext(ctx context.Context) (*Loader, bool) {
+	l, ok := ctx.Value(loaderCtxKey{t}).(*Loader)
+	return l, ok
+}
+
+// DoSelectByIDCtx reads row by id. If ctx holds the table's Loader,
+// the lookup is coalesced with concurrent ones.
+func (t *Table) DoSelectByIDCtx(ctx context.Context, id interface{}, row interface{}) error {
+	if l, ok := t.LoaderFromContext(ctx); ok {
+		return l.Load(ctx, id, row)
+	}
+	return WrapError(t, t.doSelectByIDCtx(ctx, id, row))
+}
+
+// Load reads a row by id into row. Call blocks till the batch holding id
+// is fetched or ctx is done. Returns ErrNotFound if there is no row.
+func (l *Loader) Load(ctx context.Context, id interface{}, row interface{}) error {
+
+	key, err := l.key(id)
+	if err != nil {
+		return err
+	}
+
+	dst := reflect.ValueOf(row)
+	if dst.Kind() != reflect.Ptr || dst.IsNil() || dst.Elem().Type() != reflect.TypeOf(l.t.model).Elem() {
+		return ErrInvalidScanTarget.Capture().Set("type", fmt.Sprintf("%T", row)).
+			Set("model", reflect.TypeOf(l.t.model).String())
+	}
+
+	l.mux.Lock()
+	b := l.batch
+	if b == nil {
+		b = &loaderBatch{
+			pos:  make(map[interface{}]int),
+			done: make(chan struct{}),
+		}
+		l.batch = b
+		b.timer = time.AfterFunc(l.wait, func() { l.dispatch(b) })
+	}
+
+	if _, ok := b.pos[key]; !ok {
+		b.pos[key] = len(b.keys)
+		b.keys = append(b.keys, key)
+	}
+
+	full := l.batchSize > 0 && len(b.keys) >= l.batchSize
+	if full {
+		l.batch = nil
+		b.timer.Stop()
+	}
+	l.mux.Unlock()
+
+	if full {
+		go l.run(b)
+	}
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if b.err != nil {
+		return WrapError(l.t, b.err)
+	}
+
+	res, ok := b.rows[key]
+	if !ok {
+		return parseError(sql.ErrNoRows)
+	}
+
+	dst.Elem().Set(reflect.ValueOf(res).Elem())
+	return nil
+}
+
+// dispatch runs the batch, if it has not been run by reaching batch size.
+func (l *Loader) dispatch(b *loaderBatch) {
+	l.mux.Lock()
+	if l.batch != b {
+		l.mux.Unlock()
+		return
+	}
+	l.batch = nil
+	l.mux.Unlock()
+
+	l.run(b)
+}
+
+func (l *Loader) run(b *loaderBatch) {
+	b.rows, b.err = l.fetch(l.ctx, b.keys)
+	close(b.done)
+}
+
+// key converts id to the type of model's field ID. Numbers are converted
+// to strings and back by their decimal representation. Numbers out of range
+// of the type are invalid.
+func (l *Loader) key(id interface{}) (interface{}, error) {
+
+	v := reflect.ValueOf(id)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if !v.IsValid() || v.Kind() == reflect.Ptr {
+		return nil, ErrInvalidLoaderID.Capture().Msg("nil id")
+	}
+
+	if l.idType == nil || v.Type() == l.idType {
+		return v.Interface(), nil
+	}
+
+	res := reflect.New(l.idType).Elem()
+	numbers := (isIntKind(v.Kind()) || isUintKind(v.Kind())) && (isIntKind(l.idType.Kind()) || isUintKind(l.idType.Kind()))
+	switch {
+	case numbers && overflows(v, l.idType):
+		break
+	case numbers, v.Kind() == reflect.String && l.idType.Kind() == reflect.String:
+		return v.Convert(l.idType).Interface(), nil
+	case l.idType.Kind() == reflect.String && (isIntKind(v.Kind()) || isUintKind(v.Kind())):
+		res.SetString(fmt.Sprint(v.Interface()))
+		return res.Interface(), nil
+	case v.Kind() == reflect.String && isIntKind(l.idType.Kind()):
+		n, err := strconv.ParseInt(v.String(), 10, l.idType.Bits())
+		if err == nil {
+			res.SetInt(n)
+			return res.Interface(), nil
+		}
+	case v.Kind() == reflect.String && isUintKind(l.idType.Kind()):
+		n, err := strconv.ParseUint(v.String(), 10, l.idType.Bits())
+		if err == nil {
+			res.SetUint(n)
+			return res.Interface(), nil
+		}
+	}
+
+	return nil, ErrInvalidLoaderID.Capture().Set("id", fmt.Sprint(v.Interface())).
+		Set("type", v.Type().String()).Set("expected", l.idType.String())
+}
+
+// overflows returns true if integer v can't be represented by integer
+// type typ.
+func overflows(v reflect.Value, typ reflect.Type) bool {
+	z := reflect.Zero(typ)
+	switch {
+	case isIntKind(v.Kind()) && isIntKind(typ.Kind()):
+		return z.OverflowInt(v.Int())
+	case isIntKind(v.Kind()):
+		return v.Int() < 0 || z.OverflowUint(uint64(v.Int()))
+	case isIntKind(typ.Kind()):
+		return v.Uint() > math.MaxInt64 || z.OverflowInt(int64(v.Uint()))
+	}
+	return z.OverflowUint(v.Uint())
+}
+
+func isIntKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUintKind(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uint64
+}
+
+func (l *Loader) fetchByIDs(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+
+	typ := reflect.TypeOf(l.t.model).Elem()
+	tmp := reflect.New(typ)
+	cols := l.t.fieldAddrsSelect(tmp.Interface(), "", All)
+	res := make(map[interface{}]interface{}, len(keys))
+
+	f := func() error {
+		cp := reflect.New(typ)
+		cp.Elem().Set(tmp.Elem())
+		res[cp.Elem().FieldByName(PrimaryKeyFieldName).Interface()] = cp.Interface()
+		return nil
+	}
+
+	qry := l.t.SQL.Select + " WHERE id = ANY($1)"
+	err := l.t.instance(ctx, nil, qry).QueryContext(ctx, pq.Array(keys)).Fetch(f, cols...).Err()
+	return res, err
+}
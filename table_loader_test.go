@@ -0,0 +1,162 @@
+package dbw
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/axkit/errors"
+)
+
+func TestLoader_Load(t *testing.T) {
+
+	type Row struct {
+		ID   int
+		Name string
+	}
+
+	tbl := NewTable(&DB{}, "x", &Row{})
+	ctx := tbl.WithLoader(context.Background(), WithLoaderWait(10*time.Millisecond), WithLoaderBatchSize(10))
+
+	l, ok := tbl.LoaderFromContext(ctx)
+	if !ok {
+		t.Fatal("expected loader in context")
+	}
+
+	var (
+		mx      sync.Mutex
+		batches [][]interface{}
+	)
+	l.fetch = func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+		mx.Lock()
+		batches = append(batches, keys)
+		mx.Unlock()
+		res := make(map[interface{}]interface{})
+		for _, k := range keys {
+			if k.(int) == 0 {
+				continue
+			}
+			res[k] = &Row{ID: k.(int), Name: "row"}
+		}
+		return res, nil
+	}
+
+	ids := []interface{}{1, int64(1), 2, 2, 0}
+	rows := make([]Row, len(ids))
+	errs := make([]error, len(ids))
+
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = tbl.DoSelectByIDCtx(ctx, ids[i], &rows[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 4; i++ {
+		if errs[i] != nil {
+			t.Errorf("id %v: unexpected error %v", ids[i], errs[i])
+		}
+		if rows[i].Name != "row" {
+			t.Errorf("id %v: row not loaded", ids[i])
+		}
+	}
+
+	if errs[4] == nil {
+		t.Error("expected not found error for id 0")
+	}
+
+	if len(batches) != 1 {
+		t.Fatalf("expected 1 batch, got %d", len(batches))
+	}
+
+	if len(batches[0]) != 3 {
+		t.Errorf("expected 3 unique keys, got %v", batches[0])
+	}
+}
+
+func TestLoader_key(t *testing.T) {
+
+	type IntRow struct {
+		ID int
+	}
+	type StrRow struct {
+		ID string
+	}
+	type Int8Row struct {
+		ID int8
+	}
+	type UintRow struct {
+		ID uint
+	}
+
+	id := int64(7)
+	var nilID *int
+
+	tc := []struct {
+		name  string
+		model interface{}
+		id    interface{}
+		exp   interface{}
+		fails bool
+	}{
+		{"int", &IntRow{}, 7, 7, false},
+		{"int64", &IntRow{}, int64(7), 7, false},
+		{"uint", &IntRow{}, uint8(7), 7, false},
+		{"pointer", &IntRow{}, &id, 7, false},
+		{"string-to-int", &IntRow{}, "7", 7, false},
+		{"int-to-string", &StrRow{}, 65, "65", false},
+		{"string", &StrRow{}, "a", "a", false},
+		{"nil", &IntRow{}, nil, nil, true},
+		{"nil-pointer", &IntRow{}, nilID, nil, true},
+		{"not-number", &IntRow{}, "abc", nil, true},
+		{"float", &IntRow{}, 1.5, nil, true},
+		{"uint-to-int8", &Int8Row{}, uint(100), int8(100), false},
+		{"uint-overflow", &IntRow{}, uint64(1 << 63), nil, true},
+		{"int-overflow", &Int8Row{}, 300, nil, true},
+		{"negative-to-uint", &UintRow{}, -1, nil, true},
+	}
+
+	for i := range tc {
+		l := NewTable(&DB{}, "x", tc[i].model).NewLoader(context.Background())
+		key, err := l.key(tc[i].id)
+		if tc[i].fails {
+			if !errors.Is(err, ErrInvalidLoaderID) {
+				t.Errorf("%s: expected ErrInvalidLoaderID, got %v", tc[i].name, err)
+			}
+			continue
+		}
+		if err != nil || key != tc[i].exp {
+			t.Errorf("%s: expected %#v, got %#v, %v", tc[i].name, tc[i].exp, key, err)
+		}
+	}
+}
+
+func TestLoader_LoadInvalid(t *testing.T) {
+
+	type Row struct {
+		ID int
+	}
+	type Other struct {
+		ID int
+	}
+
+	l := NewTable(&DB{}, "x", &Row{}).NewLoader(context.Background())
+	l.fetch = func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+		t.Error("unexpected fetch")
+		return nil, nil
+	}
+
+	if err := l.Load(context.Background(), nil, &Row{}); !errors.Is(err, ErrInvalidLoaderID) {
+		t.Errorf("expected ErrInvalidLoaderID, got %v", err)
+	}
+	if err := l.Load(context.Background(), 1, &Other{}); !errors.Is(err, ErrInvalidScanTarget) {
+		t.Errorf("expected ErrInvalidScanTarget, got %v", err)
+	}
+	if err := l.Load(context.Background(), 1, Row{}); !errors.Is(err, ErrInvalidScanTarget) {
+		t.Errorf("expected ErrInvalidScanTarget, got %v", err)
+	}
+}
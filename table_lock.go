@@ -0,0 +1,90 @@
+package dbw
+
+import (
+	"github.com/axkit/errors"
+)
+
+// ErrRowLockWithoutTx returns if row lock requested outside of transaction.
+var ErrRowLockWithoutTx = errors.New("row lock requires transaction").StatusCode(500)
+
+// RowLock describes locking clause of SELECT statement. Row locks are
+// valid only inside a transaction.
+//
+// RowLock can be passed as a query parameter to DoSelectTx and
+// DoSelectRowTx or as the last argument of DoSelectByIDTx.
+//
+//	err := t.DoSelectTx(tx, "status=$1", "", 0, 10, f, &row, dbw.ForUpdate.SkipLocked(), "new")
+type RowLock struct {
+	strength string
+	wait     string
+}
+
+var (
+	// ForUpdate locks selected rows as for update.
+	ForUpdate = RowLock{strength: "FOR UPDATE"}
+
+	// ForNoKeyUpdate locks selected rows as for update not touching key columns.
+	ForNoKeyUpdate = RowLock{strength: "FOR NO KEY UPDATE"}
+
+	// ForShare acquires shared lock on selected rows.
+	ForShare = RowLock{strength: "FOR SHARE"}
+
+	// ForKeyShare acquires shared lock on keys of selected rows.
+	ForKeyShare = RowLock{strength: "FOR KEY SHARE"}
+)
+
+// NoWait returns lock reporting error instead of waiting for locked rows.
+func (rl RowLock) NoWait() RowLock {
+	rl.wait = "NOWAIT"
+	return rl
+}
+
+// SkipLocked returns lock skipping rows locked by others.
+func (rl RowLock) SkipLocked() RowLock {
+	rl.wait = "SKIP LOCKED"
+	return rl
+}
+
+// IsEmpty returns true if no lock specified.
+func (rl RowLock) IsEmpty() bool {
+	return rl.strength == ""
+}
+
+// String returns locking clause.
+func (rl RowLock) String() string {
+	if rl.wait == "" {
+		return rl.strength
+	}
+	return rl.strength + " " + rl.wait
+}
+
+// extractRowLock removes RowLock from query parameters.
+func extractRowLock(params []interface{}) (RowLock, []interface{}) {
+	var lock RowLock
+
+	res := params[:0:0]
+	for i := range params {
+		if rl, ok := params[i].(RowLock); ok {
+			lock = rl
+			continue
+		}
+		res = append(res, params[i])
+	}
+
+	if lock.IsEmpty() {
+		return lock, params
+	}
+	return lock, res
+}
+
+// lockClause returns locking clause for the query or error if lock
+// requested outside of transaction.
+func lockClause(tx *Tx, lock RowLock) (string, error) {
+	if lock.IsEmpty() {
+		return "", nil
+	}
+	if tx == nil {
+		return "", ErrRowLockWithoutTx.Capture()
+	}
+	return " " + lock.String(), nil
+}
@@ -0,0 +1,22 @@
+package dbw
+
+import "testing"
+
+func TestExtractRowLock(t *testing.T) {
+
+	lock, params := extractRowLock([]interface{}{1, ForUpdate.SkipLocked(), "a"})
+	if lock.String() != "FOR UPDATE SKIP LOCKED" {
+		t.Errorf("unexpected lock %q", lock.String())
+	}
+	if len(params) != 2 || params[0] != 1 || params[1] != "a" {
+		t.Errorf("unexpected params %v", params)
+	}
+
+	if _, err := lockClause(nil, ForShare.NoWait()); err == nil {
+		t.Error("expected error for lock outside of transaction")
+	}
+
+	if lc, err := lockClause(&Tx{}, ForNoKeyUpdate.NoWait()); err != nil || lc != " FOR NO KEY UPDATE NOWAIT" {
+		t.Errorf("unexpected lock clause %q, %v", lc, err)
+	}
+}
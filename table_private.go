@@ -102,6 +102,40 @@ func (t *Table) genInsertSQL() string {
 	return s
 }
 
+// maxQueryParams holds maximum number of parameters of a statement
+// supported by PostgreSQL.
+const maxQueryParams = 65535
+
+// genInsertBatchSQL returns INSERT statement of rows rows having n
+// parameters each.
+func (t *Table) genInsertBatchSQL(rows, n int) string {
+	var sb strings.Builder
+
+	sb.WriteString("INSERT INTO " + t.name + "(" + t.fieldNames(t.model, TagNoIns, Exclude) + ") VALUES ")
+	for r := 0; r < rows; r++ {
+		if r > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteByte('(')
+		if t.isSequenceUsed {
+			sb.WriteString("NEXTVAL('" + t.name + "_seq'), ")
+		}
+		for i := 1; i <= n; i++ {
+			if i > 1 {
+				sb.WriteString(", ")
+			}
+			switch t.DB().PlaceHolderType() {
+			case QuestionMark:
+				sb.WriteByte('?')
+			case DollarPlusPosition:
+				sb.WriteString("$" + strconv.Itoa(r*n+i))
+			}
+		}
+		sb.WriteByte(')')
+	}
+	return sb.String()
+}
+
 func (t *Table) initColTag(model interface{}) {
 
 	s := reflect.ValueOf(model).Elem()
@@ -592,11 +626,47 @@ func (t *Table) fieldAddrsSelect(model interface{}, cstags string, rule TagExclu
 }
 
 func (t *Table) doSelectByIDCtx(ctx context.Context, id interface{}, row interface{}) error {
+	return t.doSelectByIDCtxTx(ctx, nil, id, row, RowLock{})
+}
+
+func (t *Table) doSelectByIDCtxTx(ctx context.Context, tx *Tx, id interface{}, row interface{}, lock RowLock) error {
+	lc, err := lockClause(tx, lock)
+	if err != nil {
+		return err
+	}
+
 	cols := t.fieldAddrsSelect(row, "", All)
-	return t.db.QueryRowContext(ctx, t.SQL.SelectByID, id).Scan(cols...)
+	return t.instance(ctx, tx, t.SQL.SelectByID+lc).QueryRowContext(ctx, id).Scan(cols...)
 }
 
 func (t *Table) doSelectRowCtx(ctx context.Context, where string, row interface{}, args ...interface{}) error {
+	return t.doSelectRowCtxTx(ctx, nil, where, row, args...)
+}
+
+func (t *Table) doSelectRowCtxTx(ctx context.Context, tx *Tx, where string, row interface{}, args ...interface{}) error {
+	lock, args := extractRowLock(args)
+	lc, err := lockClause(tx, lock)
+	if err != nil {
+		return err
+	}
+
 	cols := t.fieldAddrsSelect(row, "", All)
-	return t.db.QueryRowContext(ctx, t.SQL.Select+" where "+where, args...).Scan(cols...)
+	qry := t.SQL.Select + " where " + where + lc
+	qry, args = t.db.expandSlices(ctx, qry, args)
+	return t.instance(ctx, tx, qry).QueryRowContext(ctx, args...).Scan(cols...)
+}
+
+// prepareContext prepares statement of the table. Statement uid is hash generated.
+func (t *Table) prepareContext(ctx context.Context, qry string) *Stmt {
+	return t.db.prepareContext(ctx, calcHash([]byte(qry)), qry, t.name, false)
+}
+
+// instance prepares statement of the table and returns its instance
+// bound to tx, if tx is not nil.
+func (t *Table) instance(ctx context.Context, tx *Tx, qry string) *StmtInstance {
+	stmt := t.prepareContext(ctx, qry)
+	if tx == nil {
+		return stmt.Instance()
+	}
+	return stmt.InstanceTx(tx)
 }
@@ -0,0 +1,91 @@
+package dbw
+
+import (
+	"context"
+)
+
+// Span operations.
+const (
+	SpanPrepare  = "prepare"
+	SpanQuery    = "query"
+	SpanExec     = "exec"
+	SpanCommit   = "commit"
+	SpanRollback = "rollback"
+)
+
+// SpanInfo describes database operation traced by Tracer.
+type SpanInfo struct {
+	// Op holds operation name: SpanPrepare, SpanQuery, etc.
+	Op string
+
+	// UID and SQL hold prepared statement uid and text. Empty for
+	// transaction operations.
+	UID string
+	SQL string
+
+	// Table holds table name if statement is prepared by Table.
+	Table string
+
+	// TxID holds transaction ID or zero.
+	TxID uint64
+}
+
+// Span represents traced database operation.
+type Span interface {
+	// End finishes the span. Parameter rows holds number of fetched rows.
+	End(rows int, err error)
+}
+
+// Tracer starts spans around database operations. Context returned by Start
+// is passed to database driver.
+type Tracer interface {
+	Start(ctx context.Context, info SpanInfo) (context.Context, Span)
+}
+
+// SetTracer sets tracer of database operations.
+func (db *DB) SetTracer(t Tracer) {
+	db.optMux.Lock()
+	db.tracer = t
+	db.optMux.Unlock()
+}
+
+// Tracer returns tracer set by SetTracer.
+func (db *DB) Tracer() Tracer {
+	db.optMux.RLock()
+	defer db.optMux.RUnlock()
+	return db.tracer
+}
+
+// startSpan starts span if tracer is set. Returned span is nil otherwise.
+func (db *DB) startSpan(ctx context.Context, info SpanInfo) (context.Context, Span) {
+	if db == nil {
+		return ctx, nil
+	}
+
+	tracer := db.Tracer()
+	if tracer == nil {
+		return ctx, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return tracer.Start(ctx, info)
+}
+
+// endSpan finishes span if it's not nil.
+func endSpan(s Span, rows int, err error) {
+	if s != nil {
+		s.End(rows, err)
+	}
+}
+
+// spanInfo returns span description of the statement execution.
+func (si *StmtInstance) spanInfo(op string) SpanInfo {
+	res := SpanInfo{Op: op, TxID: si.TxID}
+	if si.stmt != nil {
+		res.UID = si.stmt.uid
+		res.SQL = si.stmt.text
+		res.Table = si.stmt.table
+	}
+	return res
+}
@@ -0,0 +1,60 @@
+package dbw
+
+import (
+	"context"
+	"testing"
+)
+
+type testSpan struct {
+	info SpanInfo
+	rows int
+	err  error
+	done bool
+}
+
+func (s *testSpan) End(rows int, err error) {
+	s.rows, s.err, s.done = rows, err, true
+}
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+type spanKey struct{}
+
+func (t *testTracer) Start(ctx context.Context, info SpanInfo) (context.Context, Span) {
+	s := &testSpan{info: info}
+	t.spans = append(t.spans, s)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func TestStmtInstance_span(t *testing.T) {
+
+	tr := &testTracer{}
+	db := &DB{}
+	db.SetTracer(tr)
+
+	si := &StmtInstance{stmt: &Stmt{db: db, uid: "u1", text: "SELECT 1", table: "customers"}, TxID: 7}
+	ctx := si.start(context.Background(), SpanQuery, nil)
+	if ctx.Value(spanKey{}) == nil {
+		t.Error("expected context returned by tracer")
+	}
+
+	si.RowsFetched = 3
+	si.finish()
+	si.finish()
+
+	if len(tr.spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(tr.spans))
+	}
+
+	s := tr.spans[0]
+	exp := SpanInfo{Op: SpanQuery, UID: "u1", SQL: "SELECT 1", Table: "customers", TxID: 7}
+	if s.info != exp {
+		t.Errorf("expected %+v, got %+v", exp, s.info)
+	}
+
+	if !s.done || s.rows != 3 {
+		t.Errorf("unexpected span end: done=%v rows=%d", s.done, s.rows)
+	}
+}
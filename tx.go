@@ -3,41 +3,67 @@ package dbw
 import (
 	"context"
 	"database/sql"
+	"strconv"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/axkit/errors"
+	"github.com/lib/pq"
 )
 
 // Tx describes transaction.
 type Tx struct {
 	db       *DB
+	ctx      context.Context
 	sqlTx    *sql.Tx
 	err      error
 	id       uint64
 	started  time.Time
 	finished time.Time
+
+	// savepoints holds names of active savepoints in order of establishing.
+	savepoints []string
+
+	// spSeq holds number of the last automatic savepoint, see InTx.
+	spSeq int
 }
 
 func newTx(ctx context.Context, opts *sql.TxOptions, db *DB, transactionID uint64) *Tx {
-	tx := &Tx{db: db, id: transactionID, started: time.Now()}
+	tx := &Tx{db: db, ctx: ctx, id: transactionID, started: time.Now()}
 	tx.sqlTx, tx.err = db.SQLDB().BeginTx(ctx, opts)
 	return tx
 }
 
 // Commit commits transaction Tx.
 func (tx *Tx) Commit() *Tx {
+	_, span := tx.db.startSpan(tx.ctx, SpanInfo{Op: SpanCommit, TxID: tx.id})
 	tx.finished = time.Now()
 	tx.err = tx.sqlTx.Commit()
+	endSpan(span, 0, tx.err)
+	tx.done(tx.err == nil)
 	return tx
 }
 
 // Rollback rollbacks transaction.
 func (tx *Tx) Rollback() *Tx {
+	_, span := tx.db.startSpan(tx.ctx, SpanInfo{Op: SpanRollback, TxID: tx.id})
 	tx.finished = time.Now()
 	tx.err = tx.sqlTx.Rollback()
+	endSpan(span, 0, tx.err)
+	tx.done(false)
 	return tx
 }
 
+// done updates transaction statistics of the database.
+func (tx *Tx) done(committed bool) {
+	if tx.err == sql.ErrTxDone || tx.db == nil {
+		return
+	}
+
+	tx.db.txStats.add(tx.Duration(), committed)
+	tx.db.errStats.add(tx.err)
+}
+
 // SQLTx returns original *sql.Tx object.
 func (tx *Tx) SQLTx() *sql.Tx {
 	return tx.sqlTx
@@ -62,3 +88,101 @@ func (tx *Tx) Duration() time.Duration {
 func (tx *Tx) Err() error {
 	return tx.err
 }
+
+// Savepoint establishes savepoint name inside the transaction.
+func (tx *Tx) Savepoint(name string) error {
+	return tx.savepoint(tx.ctx, name)
+}
+
+// RollbackTo rolls back all statements executed after savepoint name was
+// established. The savepoint remains valid and can be rolled back to again.
+func (tx *Tx) RollbackTo(name string) error {
+	return tx.rollbackTo(tx.ctx, name)
+}
+
+// Release destroys savepoint name keeping effects of statements executed
+// after it was established. Savepoints established later are destroyed too.
+func (tx *Tx) Release(name string) error {
+	return tx.release(tx.ctx, name)
+}
+
+// InTx calls f inside nested transaction implemented by automatic
+// savepoint. If f returns error, the savepoint is rolled back and the
+// error is returned, the outer transaction stays usable. Otherwise the
+// savepoint is released.
+func (tx *Tx) InTx(f func(*Tx) error) error {
+	tx.spSeq++
+	name := "dbw_tx_" + strconv.Itoa(tx.spSeq)
+
+	if err := tx.savepoint(tx.ctx, name); err != nil {
+		return err
+	}
+
+	if err := f(tx); err != nil {
+		if rerr := tx.rollbackTo(tx.ctx, name); rerr != nil {
+			return rerr
+		}
+		_ = tx.release(tx.ctx, name)
+		return err
+	}
+
+	return tx.release(tx.ctx, name)
+}
+
+func (tx *Tx) savepoint(ctx context.Context, name string) error {
+	if err := tx.execSavepoint(ctx, "SAVEPOINT ", name); err != nil {
+		return err
+	}
+
+	tx.savepoints = append(tx.savepoints, name)
+	return nil
+}
+
+// rollbackTo rolls back to savepoint name. Savepoints established after it
+// are destroyed.
+func (tx *Tx) rollbackTo(ctx context.Context, name string) error {
+	if err := tx.execSavepoint(ctx, "ROLLBACK TO SAVEPOINT ", name); err != nil {
+		return err
+	}
+	if i := tx.savepointIndex(name); i >= 0 {
+		tx.savepoints = tx.savepoints[:i+1]
+	}
+	return nil
+}
+
+// release releases savepoint name and all savepoints established after it.
+func (tx *Tx) release(ctx context.Context, name string) error {
+	if err := tx.execSavepoint(ctx, "RELEASE SAVEPOINT ", name); err != nil {
+		return err
+	}
+	if i := tx.savepointIndex(name); i >= 0 {
+		tx.savepoints = tx.savepoints[:i]
+	}
+	return nil
+}
+
+// savepointIndex returns index of the latest savepoint name or -1.
+func (tx *Tx) savepointIndex(name string) int {
+	for i := len(tx.savepoints) - 1; i >= 0; i-- {
+		if tx.savepoints[i] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// execSavepoint executes savepoint command cmd over savepoint name.
+func (tx *Tx) execSavepoint(ctx context.Context, cmd, name string) error {
+	if tx.err != nil {
+		return tx.err
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if _, err := tx.sqlTx.ExecContext(ctx, cmd+pq.QuoteIdentifier(name)); err != nil {
+		return errors.Catch(parseError(err)).Set("cmd", strings.TrimSpace(cmd)).Set("savepoint", name).Msg("savepoint command failed")
+	}
+	return nil
+}
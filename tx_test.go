@@ -0,0 +1,96 @@
+package dbw
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/axkit/errors"
+)
+
+func TestTx_InTxFailed(t *testing.T) {
+
+	failed := errors.New("begin failed")
+	tx := &Tx{err: failed}
+
+	called := false
+	err := tx.InTx(func(*Tx) error {
+		called = true
+		return nil
+	})
+
+	if called {
+		t.Error("expected f not to be called in failed transaction")
+	}
+	if err != failed {
+		t.Errorf("expected transaction error, got %v", err)
+	}
+	if err := tx.Release("sp"); err != failed {
+		t.Errorf("expected transaction error, got %v", err)
+	}
+}
+
+func TestTx_SavepointIndex(t *testing.T) {
+
+	tx := &Tx{savepoints: []string{"a", "b", "a", "c"}}
+
+	if i := tx.savepointIndex("a"); i != 2 {
+		t.Errorf("expected latest savepoint index 2, got %d", i)
+	}
+	if i := tx.savepointIndex("x"); i != -1 {
+		t.Errorf("expected -1, got %d", i)
+	}
+}
+
+func TestTx_InTxReprepare(t *testing.T) {
+
+	failed := errors.New("nested failed")
+
+	db, srv := newFakeDB(t, func(c *fakeCall) (*fakeRows, error) {
+		if c.Query == "SELECT 1" {
+			return &fakeRows{cols: []string{"n"}, vals: [][]driver.Value{{int64(1)}}}, nil
+		}
+		return nil, nil
+	})
+	db.SetReprepareInTx(true)
+
+	tx := db.Begin()
+
+	err := tx.InTx(func(tx *Tx) error {
+		if _, err := db.ExecTx(tx, "UPDATE t SET a = 1"); err != nil {
+			return err
+		}
+		var n int
+		if err := db.QueryRowTx(tx, "SELECT 1").Scan(&n); err != nil {
+			return err
+		}
+		return tx.InTx(func(tx *Tx) error {
+			_, err := db.ExecTx(tx, "UPDATE t SET a = 2")
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("expected nested transaction committed, got %v", err)
+	}
+
+	err = tx.InTx(func(tx *Tx) error {
+		if _, err := db.ExecTx(tx, "UPDATE t SET a = 3"); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected error returned by f, got %v", err)
+	}
+
+	if _, err := db.ExecTx(tx, "UPDATE t SET a = 4"); err != nil {
+		t.Fatalf("expected outer transaction usable, got %v", err)
+	}
+
+	if err := tx.Commit().Err(); err != nil {
+		t.Fatalf("commit failed: %v\n%s", err, strings.Join(srv.Log(), "\n"))
+	}
+	if len(tx.savepoints) != 0 {
+		t.Errorf("expected no savepoints left, got %v", tx.savepoints)
+	}
+}